@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+
+	"github.com/mark3labs/mcphost/pkg/agent"
+)
+
+// Tool call policies that can be set per tool in the mcpServers config
+const (
+	policyAuto = "auto"
+	policyAsk  = "ask"
+	policyDeny = "deny"
+)
+
+// Choices offered when the user is asked to approve a tool call
+const (
+	approveOnce       = "approve"
+	approveDeny       = "deny"
+	approveToolAlways = "tool"
+	approveAllServer  = "server"
+)
+
+var approvalStyle = lipgloss.NewStyle().
+	Border(lipgloss.RoundedBorder()).
+	BorderForeground(tokyoOrange).
+	Padding(0, 1).
+	MarginLeft(2)
+
+// toolApprover decides whether a tool call may run. Tools with the "ask"
+// policy need the user's approval, and are denied when nobody can approve
+// them. Tools and servers the user allowed for the rest of the session are
+// remembered.
+type toolApprover struct {
+	config      *MCPConfig
+	interactive bool
+
+	mu             sync.Mutex
+	allowedTools   map[string]bool
+	allowedServers map[string]bool
+}
+
+func newToolApprover(config *MCPConfig, interactive bool) *toolApprover {
+	return &toolApprover{
+		config:         config,
+		interactive:    interactive,
+		allowedTools:   make(map[string]bool),
+		allowedServers: make(map[string]bool),
+	}
+}
+
+// toolPolicy returns the policy for a tool. Tools without a policy need to
+// be approved.
+func (o ServerOptions) toolPolicy(toolName string) string {
+	if policy, ok := matchTool(o.ToolPolicies, toolName); ok {
+		return policy
+	}
+	return policyAsk
+}
+
+// matchTool looks a tool up in a map of tool names and glob patterns. An
+// exact match of the tool name wins over patterns, and longer patterns win
+// over shorter ones.
+func matchTool[T any](values map[string]T, toolName string) (T, bool) {
+	if value, ok := values[toolName]; ok {
+		return value, true
+	}
+
+	patterns := make([]string, 0, len(values))
+	for pattern := range values {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, toolName); matched {
+			return values[pattern], true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// approve reports whether a tool call may run. If it may not, the returned
+// reason is sent back to the model as the result of the call.
+func (a *toolApprover) approve(
+	serverName, toolName string,
+	args map[string]interface{},
+) (bool, string) {
+	// mcphost's own tools only read from the servers
+	if serverName == hostServerName {
+		return true, ""
+	}
+
+	options := a.config.serverOptions(serverName)
+	if !options.toolEnabled(toolName) {
+		log.Warn("Disabled tool called", "server", serverName, "tool", toolName)
+		return false, fmt.Sprintf("Tool %s is not available.", toolName)
+	}
+
+	switch options.toolPolicy(toolName) {
+	case policyAuto:
+		return true, ""
+	case policyDeny:
+		log.Warn("Tool call denied by policy", "server", serverName, "tool", toolName)
+		return false, fmt.Sprintf("Calling tool %s is not allowed by the configured policy.", toolName)
+	}
+
+	// Without a user to ask, only tools set to "auto" run
+	if !a.interactive {
+		log.Warn("Tool call needs approval, but nobody can approve it", "server", serverName, "tool", toolName)
+		return false, fmt.Sprintf("Calling tool %s needs the approval of the user, who can't be asked.", toolName)
+	}
+
+	a.mu.Lock()
+	allowed := a.allowedServers[serverName] || a.allowedTools[agent.ToolName(serverName, toolName)]
+	a.mu.Unlock()
+	if allowed {
+		return true, ""
+	}
+
+	return a.ask(serverName, toolName, args)
+}
+
+func (a *toolApprover) ask(
+	serverName, toolName string,
+	args map[string]interface{},
+) (bool, string) {
+	prettyArgs, err := json.MarshalIndent(args, "", "  ")
+	if err != nil {
+		prettyArgs = []byte(fmt.Sprintf("%v", args))
+	}
+
+	fmt.Printf("\n%s\n", approvalStyle.Render(fmt.Sprintf(
+		"%s %s\n%s %s\n\n%s",
+		toolNameStyle.Render("Server:"), serverName,
+		toolNameStyle.Render("Tool:"), toolName,
+		string(prettyArgs),
+	)))
+
+	var choice string
+	err = huh.NewForm(huh.NewGroup(huh.NewSelect[string]().
+		Title(fmt.Sprintf("Allow %s to run %s?", serverName, toolName)).
+		Options(
+			huh.NewOption("Approve", approveOnce),
+			huh.NewOption("Deny", approveDeny),
+			huh.NewOption(fmt.Sprintf("Always allow %s this session", toolName), approveToolAlways),
+			huh.NewOption(fmt.Sprintf("Always allow every tool of %s this session", serverName), approveAllServer),
+		).
+		Value(&choice)),
+	).WithWidth(getTerminalWidth()).
+		WithTheme(huh.ThemeCharm()).
+		Run()
+	if err != nil {
+		if !errors.Is(err, huh.ErrUserAborted) {
+			log.Error("Error asking for tool approval", "error", err)
+		}
+		return false, "The user did not approve this tool call."
+	}
+
+	switch choice {
+	case approveOnce:
+		return true, ""
+	case approveToolAlways:
+		a.mu.Lock()
+		a.allowedTools[agent.ToolName(serverName, toolName)] = true
+		a.mu.Unlock()
+		return true, ""
+	case approveAllServer:
+		a.mu.Lock()
+		a.allowedServers[serverName] = true
+		a.mu.Unlock()
+		return true, ""
+	}
+
+	var reason string
+	_ = huh.NewForm(huh.NewGroup(huh.NewInput().
+		Title("Reason for denying (optional, sent to the model)").
+		Value(&reason)),
+	).WithWidth(getTerminalWidth()).
+		WithTheme(huh.ThemeCharm()).
+		Run()
+
+	if reason = strings.TrimSpace(reason); reason != "" {
+		return false, "The user denied this tool call: " + reason
+	}
+	return false, "The user denied this tool call."
+}
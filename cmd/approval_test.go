@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchTool(t *testing.T) {
+	policies := map[string]string{
+		"read_file":   policyAuto,
+		"read_*":      policyAsk,
+		"*":           policyDeny,
+		"write_*":     policyAsk,
+		"write_file*": policyAuto,
+	}
+
+	tests := []struct {
+		name     string
+		values   map[string]string
+		toolName string
+		want     string
+		wantOK   bool
+	}{
+		{name: "exact", values: policies, toolName: "read_file", want: policyAuto, wantOK: true},
+		{name: "pattern", values: policies, toolName: "read_dir", want: policyAsk, wantOK: true},
+		{name: "longer pattern", values: policies, toolName: "write_files", want: policyAuto, wantOK: true},
+		{name: "shorter pattern", values: policies, toolName: "write_dir", want: policyAsk, wantOK: true},
+		{name: "catch all", values: policies, toolName: "delete", want: policyDeny, wantOK: true},
+		{name: "no match", values: map[string]string{"read_*": policyAuto}, toolName: "delete"},
+		{name: "no values", toolName: "delete"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := matchTool(test.values, test.toolName)
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("matchTool(%q) = %q, %v, want %q, %v", test.toolName, got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestToolApproverApprove(t *testing.T) {
+	config := &MCPConfig{MCPServers: map[string]ServerConfigWrapper{
+		"fs": {Config: STDIOServerConfig{
+			Command: "fs",
+			ServerOptions: ServerOptions{
+				ToolPolicies: map[string]string{
+					"read_*":      policyAuto,
+					"delete_file": policyDeny,
+					"write_*":     policyAsk,
+					"exec_*":      policyAuto,
+				},
+				DisabledTools: []string{"exec_*"},
+			},
+		}},
+		"web": {Config: SSEServerConfig{Url: "https://example.com/sse"}},
+	}}
+
+	tests := []struct {
+		name       string
+		serverName string
+		toolName   string
+		want       bool
+		wantReason string
+	}{
+		{name: "auto", serverName: "fs", toolName: "read_file", want: true},
+		{name: "deny", serverName: "fs", toolName: "delete_file", wantReason: "not allowed by the configured policy"},
+		// Nobody can be asked, so tools that need approval are denied
+		{name: "ask", serverName: "fs", toolName: "write_file", wantReason: "needs the approval of the user"},
+		{name: "no policy", serverName: "fs", toolName: "move_file", wantReason: "needs the approval of the user"},
+		{name: "no policies", serverName: "web", toolName: "fetch", wantReason: "needs the approval of the user"},
+		// A disabled tool can't be called whatever its policy
+		{name: "disabled", serverName: "fs", toolName: "exec_command", wantReason: "is not available"},
+		{name: "unknown server", serverName: "git", toolName: "status", wantReason: "needs the approval of the user"},
+	}
+
+	approver := newToolApprover(config, false)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, reason := approver.approve(test.serverName, test.toolName, nil)
+			if ok != test.want {
+				t.Fatalf("approve() = %v (%s), want %v", ok, reason, test.want)
+			}
+			if !strings.Contains(reason, test.wantReason) {
+				t.Errorf("reason = %q, want it to contain %q", reason, test.wantReason)
+			}
+		})
+	}
+}
+
+func TestToolApproverRemembers(t *testing.T) {
+	config := &MCPConfig{MCPServers: map[string]ServerConfigWrapper{
+		"fs": {Config: STDIOServerConfig{Command: "fs"}},
+		"git": {Config: STDIOServerConfig{
+			Command:       "git",
+			ServerOptions: ServerOptions{ToolPolicies: map[string]string{"push": policyDeny}},
+		}},
+	}}
+
+	tests := []struct {
+		name       string
+		serverName string
+		toolName   string
+		want       bool
+	}{
+		{name: "allowed tool", serverName: "fs", toolName: "write_file", want: true},
+		{name: "allowed server", serverName: "git", toolName: "commit", want: true},
+		// A policy of the config wins over what the user allowed
+		{name: "denied by policy", serverName: "git", toolName: "push", want: false},
+	}
+
+	approver := newToolApprover(config, true)
+	approver.allowedTools["fs__write_file"] = true
+	approver.allowedServers["git"] = true
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if ok, reason := approver.approve(test.serverName, test.toolName, nil); ok != test.want {
+				t.Errorf("approve() = %v (%s), want %v", ok, reason, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/mark3labs/mcphost/pkg/history"
+	"github.com/mark3labs/mcphost/pkg/llm"
+	"github.com/mark3labs/mcphost/pkg/llm/openai"
+)
+
+// handleChatCompletions serves an OpenAI compatible chat completions endpoint.
+// The conversation comes from the request, the MCP tool loop runs on the
+// server and only the final answer is returned to the client.
+func handleChatCompletions(
+	ctx context.Context,
+	w http.ResponseWriter,
+	r *http.Request,
+	provider llm.Provider,
+	mcpClients map[string]mcpclient.MCPClient,
+	approver *toolApprover,
+	tools []llm.Tool,
+) {
+	ctx, cancel := requestContext(ctx, r)
+	defer cancel()
+
+	var request completionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeCompletionError(w, http.StatusBadRequest, "invalid_request_error",
+			fmt.Sprintf("error parsing request: %v", err))
+		return
+	}
+
+	// The model calls the tools of the MCP servers on the server, so tools
+	// of the client can't be offered to it
+	if len(request.Tools) > 0 || len(request.ToolChoice) > 0 && string(request.ToolChoice) != "null" {
+		writeCompletionError(w, http.StatusBadRequest, "invalid_request_error",
+			"tools and tool_choice are not supported, the model uses the tools of the MCP servers of mcphost")
+		return
+	}
+
+	messages, prompt, err := completionMessagesToHistory(request.Messages)
+	if err != nil {
+		writeCompletionError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	// Requests run on the model mcphost was started with, whatever model
+	// they ask for, so the response names that one
+	model := modelFlag
+	id := completionID()
+	created := time.Now().Unix()
+
+	var usage openai.Usage
+	hooks := promptHooks{
+		onUsage: func(inputTokens, outputTokens int) {
+			usage.PromptTokens += inputTokens
+			usage.CompletionTokens += outputTokens
+			usage.TotalTokens += inputTokens + outputTokens
+		},
+	}
+
+	if !request.Stream {
+		message, err := runPromptNonInteractive(ctx, provider, mcpClients, approver, tools, prompt, &messages, hooks)
+		if err != nil {
+			log.Error("Error running chat completion", "error", err)
+			writeCompletionError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(openai.APIResponse{
+			ID:      id,
+			Object:  "chat.completion",
+			Created: created,
+			Model:   model,
+			Usage:   usage,
+			Choices: []openai.Choice{{
+				Index: 0,
+				Message: openai.MessageParam{
+					Role:    "assistant",
+					Content: &message,
+				},
+				FinishReason: "stop",
+			}},
+		})
+		return
+	}
+
+	stream, ok := newEventStream(w)
+	if !ok {
+		writeCompletionError(w, http.StatusInternalServerError, "server_error", "streaming is not supported")
+		return
+	}
+
+	// OpenAI streams only carry data lines
+	send := func(v interface{}) {
+		stream.send("", v)
+	}
+	chunk := func(delta openai.ChunkDelta, finishReason *string) openai.ChunkResponse {
+		return openai.ChunkResponse{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   model,
+			Choices: []openai.ChunkChoice{{
+				Index:        0,
+				Delta:        delta,
+				FinishReason: finishReason,
+			}},
+		}
+	}
+
+	send(chunk(openai.ChunkDelta{Role: "assistant"}, nil))
+
+	hooks.onText = func(text string) {
+		send(chunk(openai.ChunkDelta{Content: text}, nil))
+	}
+	if _, err := runPromptNonInteractive(ctx, provider, mcpClients, approver, tools, prompt, &messages, hooks); err != nil {
+		log.Error("Error running chat completion", "error", err)
+		send(map[string]interface{}{
+			"error": map[string]string{
+				"message": err.Error(),
+				"type":    "server_error",
+			},
+		})
+		return
+	}
+
+	stop := "stop"
+	final := chunk(openai.ChunkDelta{}, &stop)
+	if request.StreamOptions != nil && request.StreamOptions.IncludeUsage {
+		final.Usage = &usage
+	}
+	send(final)
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	stream.flusher.Flush()
+}
+
+// handleModels lists the model the server was started with, which is the
+// only one it can serve
+func handleModels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"object": "list",
+		"data": []map[string]interface{}{{
+			"id":       modelFlag,
+			"object":   "model",
+			"created":  0,
+			"owned_by": "mcphost",
+		}},
+	})
+}
+
+// completionRequest is a chat completion request as clients send it, where
+// the content of a message may also be an array of parts
+type completionRequest struct {
+	openai.CreateRequest
+	Messages   []completionMessage `json:"messages"`
+	ToolChoice json.RawMessage     `json:"tool_choice,omitempty"`
+}
+
+type completionMessage struct {
+	openai.MessageParam
+	Content *completionContent `json:"content"`
+}
+
+// completionContent is the text of a message, given as a string or as an
+// array of text parts, which are joined
+type completionContent string
+
+func (c *completionContent) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = completionContent(text)
+		return nil
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("content must be a string or an array of parts")
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type != "text" {
+			return fmt.Errorf("unsupported content part type: %s", part.Type)
+		}
+		texts = append(texts, part.Text)
+	}
+	*c = completionContent(strings.Join(texts, "\n"))
+	return nil
+}
+
+// completionMessagesToHistory converts the messages of a chat completion
+// request into a history and the prompt to answer, which is the content of the
+// last message. System messages are dropped, the server's own system prompt
+// is used instead.
+func completionMessagesToHistory(
+	params []completionMessage,
+) ([]history.HistoryMessage, string, error) {
+	if len(params) == 0 {
+		return nil, "", fmt.Errorf("messages must not be empty")
+	}
+
+	last := params[len(params)-1]
+	if last.Role != "user" || last.Content == nil {
+		return nil, "", fmt.Errorf("the last message must be a user message")
+	}
+
+	messages := make([]history.HistoryMessage, 0, len(params))
+	for _, param := range params[:len(params)-1] {
+		var content string
+		if param.Content != nil {
+			content = string(*param.Content)
+		}
+
+		switch param.Role {
+		case "system", "developer":
+			log.Debug("ignoring system message from chat completion request")
+
+		case "user":
+			messages = append(messages, history.HistoryMessage{
+				Role: "user",
+				Content: []history.ContentBlock{{
+					Type: "text",
+					Text: content,
+				}},
+			})
+
+		case "assistant":
+			var blocks []history.ContentBlock
+			if content != "" {
+				blocks = append(blocks, history.ContentBlock{
+					Type: "text",
+					Text: content,
+				})
+			}
+			for _, call := range param.ToolCalls {
+				arguments := call.Function.Arguments
+				if arguments == "" {
+					arguments = "{}"
+				}
+				if !json.Valid([]byte(arguments)) {
+					return nil, "", fmt.Errorf("the arguments of tool call %s are not valid JSON", call.ID)
+				}
+				blocks = append(blocks, history.ContentBlock{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Function.Name,
+					Input: json.RawMessage(arguments),
+				})
+			}
+			messages = append(messages, history.HistoryMessage{
+				Role:    "assistant",
+				Content: blocks,
+			})
+
+		case "tool":
+			messages = append(messages, history.HistoryMessage{
+				Role: "tool",
+				Content: []history.ContentBlock{{
+					Type:      "tool_result",
+					ToolUseID: param.ToolCallID,
+					Text:      content,
+					Content:   []mcp.Content{mcp.NewTextContent(content)},
+				}},
+			})
+
+		default:
+			return nil, "", fmt.Errorf("unsupported message role: %s", param.Role)
+		}
+	}
+
+	return messages, string(*last.Content), nil
+}
+
+func writeCompletionError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{
+			"message": message,
+			"type":    errType,
+		},
+	})
+}
+
+func completionID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
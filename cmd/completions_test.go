@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcphost/pkg/history"
+	"github.com/mark3labs/mcphost/pkg/llm"
+	"github.com/mark3labs/mcphost/pkg/llm/openai"
+)
+
+// testProvider answers with the responses of a script, one per call
+type testProvider struct {
+	respond func(step int, messages []llm.Message) llm.Message
+
+	mu    sync.Mutex
+	steps int
+}
+
+func (p *testProvider) CreateMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (llm.Message, error) {
+	p.mu.Lock()
+	p.steps++
+	step := p.steps
+	p.mu.Unlock()
+	return p.respond(step, messages), nil
+}
+
+func (p *testProvider) CreateToolResponse(toolCallID string, content interface{}) (llm.Message, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *testProvider) SupportsTools() bool { return true }
+
+func (p *testProvider) Name() string { return "test" }
+
+// testResponse is a response of the model with its token usage
+type testResponse struct {
+	history.HistoryMessage
+	inputTokens, outputTokens int
+}
+
+func (r *testResponse) GetUsage() (int, int) {
+	return r.inputTokens, r.outputTokens
+}
+
+// textResponse returns a response that answers with text
+func textResponse(text string, inputTokens, outputTokens int) llm.Message {
+	return &testResponse{
+		HistoryMessage: history.HistoryMessage{
+			Role:    "assistant",
+			Content: []history.ContentBlock{{Type: "text", Text: text}},
+		},
+		inputTokens:  inputTokens,
+		outputTokens: outputTokens,
+	}
+}
+
+// useModel sets the model mcphost runs with for the rest of a test
+func useModel(t *testing.T, model string) {
+	original := modelFlag
+	modelFlag = model
+	t.Cleanup(func() { modelFlag = original })
+}
+
+// describeHistory lists the blocks of a history, one line per block
+func describeHistory(messages []history.HistoryMessage) []string {
+	var lines []string
+	for _, message := range messages {
+		for _, block := range message.Content {
+			switch block.Type {
+			case "tool_use":
+				lines = append(lines, fmt.Sprintf("%s tool_use %s %s %s", message.Role, block.ID, block.Name, block.Input))
+			case "tool_result":
+				lines = append(lines, fmt.Sprintf("%s tool_result %s %q", message.Role, block.ToolUseID, block.Text))
+			default:
+				lines = append(lines, fmt.Sprintf("%s %s %q", message.Role, block.Type, block.Text))
+			}
+		}
+	}
+	return lines
+}
+
+func TestCompletionMessagesToHistory(t *testing.T) {
+	tests := []struct {
+		name        string
+		messages    string
+		wantHistory []string
+		wantPrompt  string
+		wantErr     string
+	}{
+		{
+			name:       "prompt",
+			messages:   `[{"role": "user", "content": "Hi"}]`,
+			wantPrompt: "Hi",
+		},
+		{
+			name: "system dropped",
+			messages: `[
+				{"role": "system", "content": "Be brief"},
+				{"role": "developer", "content": "Be kind"},
+				{"role": "user", "content": "Hi"}
+			]`,
+			wantPrompt: "Hi",
+		},
+		{
+			name:       "content parts",
+			messages:   `[{"role": "user", "content": [{"type": "text", "text": "Read"}, {"type": "text", "text": "this"}]}]`,
+			wantPrompt: "Read\nthis",
+		},
+		{
+			name: "tool calls",
+			messages: `[
+				{"role": "user", "content": "List the files"},
+				{"role": "assistant", "content": null, "tool_calls": [
+					{"id": "call_1", "type": "function", "function": {"name": "fs__list", "arguments": "{\"path\":\".\"}"}},
+					{"id": "call_2", "type": "function", "function": {"name": "fs__pwd", "arguments": ""}}
+				]},
+				{"role": "tool", "tool_call_id": "call_1", "content": "a.txt"},
+				{"role": "tool", "tool_call_id": "call_2", "content": "/tmp"},
+				{"role": "assistant", "content": "There is a.txt"},
+				{"role": "user", "content": "Thanks"}
+			]`,
+			wantHistory: []string{
+				`user text "List the files"`,
+				`assistant tool_use call_1 fs__list {"path":"."}`,
+				`assistant tool_use call_2 fs__pwd {}`,
+				`tool tool_result call_1 "a.txt"`,
+				`tool tool_result call_2 "/tmp"`,
+				`assistant text "There is a.txt"`,
+			},
+			wantPrompt: "Thanks",
+		},
+		{
+			name: "invalid arguments",
+			messages: `[
+				{"role": "assistant", "tool_calls": [
+					{"id": "call_1", "type": "function", "function": {"name": "fs__list", "arguments": "{\"path\":"}}
+				]},
+				{"role": "user", "content": "Hi"}
+			]`,
+			wantErr: "the arguments of tool call call_1 are not valid JSON",
+		},
+		{
+			name:     "empty",
+			messages: `[]`,
+			wantErr:  "messages must not be empty",
+		},
+		{
+			name: "last not user",
+			messages: `[
+				{"role": "user", "content": "Hi"},
+				{"role": "assistant", "content": "Hello"}
+			]`,
+			wantErr: "the last message must be a user message",
+		},
+		{
+			name: "unsupported role",
+			messages: `[
+				{"role": "function", "content": "42"},
+				{"role": "user", "content": "Hi"}
+			]`,
+			wantErr: "unsupported message role: function",
+		},
+		{
+			name:     "image part",
+			messages: `[{"role": "user", "content": [{"type": "image_url", "image_url": {"url": "https://example.com/a.png"}}]}]`,
+			wantErr:  "unsupported content part type: image_url",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var params []completionMessage
+			err := json.Unmarshal([]byte(test.messages), &params)
+			var messages []history.HistoryMessage
+			var prompt string
+			if err == nil {
+				messages, prompt, err = completionMessagesToHistory(params)
+			}
+
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if prompt != test.wantPrompt {
+				t.Errorf("prompt = %q, want %q", prompt, test.wantPrompt)
+			}
+			got := describeHistory(messages)
+			if strings.Join(got, "\n") != strings.Join(test.wantHistory, "\n") {
+				t.Errorf("history = %q, want %q", got, test.wantHistory)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "answer",
+			body:       `{"model": "gpt-4o", "messages": [{"role": "user", "content": "Hi"}]}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "tool choice null",
+			body:       `{"model": "gpt-4o", "messages": [{"role": "user", "content": "Hi"}], "tool_choice": null}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid request",
+			body:       `{"model": "gpt-4o", "messages": `,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "error parsing request",
+		},
+		{
+			name: "tools",
+			body: `{"model": "gpt-4o", "messages": [{"role": "user", "content": "Hi"}],
+				"tools": [{"type": "function", "function": {"name": "weather", "parameters": {"type": "object"}}}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "tools and tool_choice are not supported",
+		},
+		{
+			name:       "tool choice",
+			body:       `{"model": "gpt-4o", "messages": [{"role": "user", "content": "Hi"}], "tool_choice": "auto"}`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "tools and tool_choice are not supported",
+		},
+		{
+			name: "invalid arguments",
+			body: `{"model": "gpt-4o", "messages": [
+				{"role": "assistant", "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "fs__list", "arguments": "{"}}]},
+				{"role": "user", "content": "Hi"}
+			]}`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "are not valid JSON",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useModel(t, "anthropic:claude-test")
+			provider := &testProvider{respond: func(step int, messages []llm.Message) llm.Message {
+				return textResponse("Hello", 10, 2)
+			}}
+
+			request := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(test.body))
+			recorder := httptest.NewRecorder()
+			handleChatCompletions(context.Background(), recorder, request, provider, nil,
+				newToolApprover(&MCPConfig{}, false), nil)
+
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.wantStatus, recorder.Body)
+			}
+			if test.wantError != "" {
+				var response struct {
+					Error struct {
+						Message string `json:"message"`
+						Type    string `json:"type"`
+					} `json:"error"`
+				}
+				if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+					t.Fatal(err)
+				}
+				if response.Error.Type != "invalid_request_error" || !strings.Contains(response.Error.Message, test.wantError) {
+					t.Errorf("error = %+v, want an invalid request with %q", response.Error, test.wantError)
+				}
+				return
+			}
+
+			var response openai.APIResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			// The response names the model that answered, not the requested one
+			if response.Model != "anthropic:claude-test" {
+				t.Errorf("model = %q, want anthropic:claude-test", response.Model)
+			}
+			if len(response.Choices) != 1 || response.Choices[0].Message.Content == nil ||
+				*response.Choices[0].Message.Content != "Hello" {
+				t.Errorf("choices = %+v, want the answer Hello", response.Choices)
+			}
+			if response.Usage != (openai.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12}) {
+				t.Errorf("usage = %+v, want 10 and 2 tokens", response.Usage)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletionsStream(t *testing.T) {
+	useModel(t, "anthropic:claude-test")
+	provider := &testProvider{respond: func(step int, messages []llm.Message) llm.Message {
+		return textResponse("Hello", 10, 2)
+	}}
+
+	body := `{"model": "gpt-4o", "stream": true, "stream_options": {"include_usage": true},
+		"messages": [{"role": "user", "content": "Hi"}]}`
+	request := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	handleChatCompletions(context.Background(), recorder, request, provider, nil,
+		newToolApprover(&MCPConfig{}, false), nil)
+
+	var data []string
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		if line, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			data = append(data, line)
+		} else if scanner.Text() != "" {
+			t.Errorf("unexpected line %q", scanner.Text())
+		}
+	}
+	if len(data) != 4 || data[3] != "[DONE]" {
+		t.Fatalf("data = %q, want three chunks and [DONE]", data)
+	}
+
+	var text strings.Builder
+	for i, line := range data[:3] {
+		var chunk openai.ChunkResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		if chunk.Object != "chat.completion.chunk" || chunk.Model != "anthropic:claude-test" || len(chunk.Choices) != 1 {
+			t.Fatalf("chunk %d = %s", i, line)
+		}
+		text.WriteString(chunk.Choices[0].Delta.Content)
+
+		last := i == 2
+		if finished := chunk.Choices[0].FinishReason != nil; finished != last {
+			t.Errorf("chunk %d finished = %v, want %v", i, finished, last)
+		}
+		if last && (chunk.Usage == nil || chunk.Usage.TotalTokens != 12) {
+			t.Errorf("usage = %+v, want 12 tokens", chunk.Usage)
+		}
+	}
+	if text.String() != "Hello" {
+		t.Errorf("text = %q, want Hello", text.String())
+	}
+}
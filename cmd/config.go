@@ -0,0 +1,582 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/mark3labs/mcphost/pkg/llm"
+)
+
+// userConfigNames are the names of the user config in the home directory,
+// and projectConfigNames the names of the config of a project, found in the
+// current directory or one of its parents. The first one that exists is
+// used.
+var (
+	userConfigNames    = []string{".mcp.json", ".mcp.yaml", ".mcp.yml"}
+	projectConfigNames = []string{".mcphost.json", ".mcphost.yaml", ".mcphost.yml"}
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the mcphost configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective config and where each value came from",
+	Long: `Print the config that results from merging the user config
+($HOME/.mcp.json or --config), the project config (.mcphost.json in the
+current directory or the closest parent that has one) and the flags.
+Either config may also be YAML, as .mcp.yaml or .mcphost.yaml.
+
+API keys and the values of env and headers that don't refer to variables
+are redacted.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadMCPConfig(cmd.Flags(), "")
+		if err != nil {
+			return err
+		}
+		return printConfig(cmd.OutOrStdout(), config)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// loadMCPConfig merges the config layers. The user config comes first, then
+// the project config, then the flags. Servers are merged by name, so a
+// later layer replaces or disables a server of an earlier one. Settings
+// given by a later layer replace the earlier ones.
+//
+// The profile of the config, or the one picked with --profile, is applied
+// over the config files and under the flags. A profile given here wins over
+// both, and over the flags for the settings it defines.
+func loadMCPConfig(flags *pflag.FlagSet, profile string) (*MCPConfig, error) {
+	config := &MCPConfig{
+		MCPServers: make(map[string]ServerConfigWrapper),
+		sources:    make(map[string]string),
+	}
+
+	userPath := configFile
+	if userPath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("error getting home directory: %w", err)
+		}
+		userPath = filepath.Join(homeDir, userConfigNames[0])
+		for _, name := range userConfigNames {
+			if _, err := os.Stat(filepath.Join(homeDir, name)); err == nil {
+				userPath = filepath.Join(homeDir, name)
+				break
+			}
+		}
+	}
+	userPath, err := filepath.Abs(userPath)
+	if err != nil {
+		return nil, fmt.Errorf("error getting config path: %w", err)
+	}
+
+	layer, err := readConfigFile(userPath, false)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && configFile == "":
+		log.Debug("No user config file", "path", userPath)
+	case err != nil:
+		return nil, err
+	default:
+		config.files = append(config.files, userPath)
+		config.merge(layer, userPath)
+	}
+
+	projectPath, err := findProjectConfig()
+	if err != nil {
+		return nil, err
+	}
+	if projectPath != "" && projectPath != userPath {
+		layer, err := readConfigFile(projectPath, true)
+		if err != nil {
+			return nil, err
+		}
+		config.files = append(config.files, projectPath)
+		config.checkProjectTrust(projectPath, layer)
+		config.merge(layer, projectPath)
+	}
+
+	if err := restoreFlags(flags); err != nil {
+		return nil, err
+	}
+
+	// A profile switched to with /profile is applied over the flags, so
+	// that it sets what it defines. Otherwise the flags win.
+	switched := profile != ""
+	switch {
+	case switched:
+		config.sources["profile"] = "switched with /profile"
+		config.applyFlags(flags)
+	case flags.Changed("profile"):
+		profile = profileFlag
+		config.sources["profile"] = "--profile flag"
+	default:
+		profile = config.Profile
+	}
+	if profile != "" {
+		config.Profile = profile
+		if err := config.applyProfile(profile); err != nil {
+			return nil, err
+		}
+	}
+	if !switched {
+		config.applyFlags(flags)
+	}
+
+	return config, nil
+}
+
+// commandLine keeps the values of the flags given on the command line.
+// useSettings overwrites the variables of the flags with the settings in
+// use, so they are set back before the config is loaded again.
+var commandLine struct {
+	flags  *pflag.FlagSet
+	values map[string][]string
+}
+
+// restoreFlags sets the flags given on the command line back to the values
+// they were given. The first call records them.
+func restoreFlags(flags *pflag.FlagSet) error {
+	if commandLine.flags != flags {
+		commandLine.flags = flags
+		commandLine.values = make(map[string][]string)
+		flags.Visit(func(flag *pflag.Flag) {
+			if slice, ok := flag.Value.(pflag.SliceValue); ok {
+				commandLine.values[flag.Name] = slice.GetSlice()
+			} else {
+				commandLine.values[flag.Name] = []string{flag.Value.String()}
+			}
+		})
+		return nil
+	}
+
+	for name, values := range commandLine.values {
+		flag := flags.Lookup(name)
+		var err error
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			err = slice.Replace(values)
+		} else {
+			err = flag.Value.Set(values[0])
+		}
+		if err != nil {
+			return fmt.Errorf("error restoring --%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// readConfigFile reads and validates a config file. Relative paths are
+// made relative to the directory of the file. A project config is checked
+// for settings only the user may make.
+func readConfigFile(path string, project bool) (*MCPConfig, error) {
+	configData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+
+	var config MCPConfig
+	if err := decodeConfig(path, configData, &config, project); err != nil {
+		return nil, err
+	}
+
+	config.digest = configDigest(configData)
+	dir := filepath.Dir(path)
+	config.SystemPromptFile = relativeTo(dir, config.SystemPromptFile)
+	for _, profile := range config.Profiles {
+		if profile != nil {
+			profile.SystemPromptFile = relativeTo(dir, profile.SystemPromptFile)
+		}
+	}
+	for i, root := range config.Roots {
+		config.Roots[i] = relativeTo(dir, root)
+	}
+	if config.Secrets != nil {
+		for i, dotenv := range config.Secrets.Dotenv {
+			config.Secrets.Dotenv[i] = relativeTo(dir, dotenv)
+		}
+	}
+	return &config, nil
+}
+
+// validateProjectConfig rejects the settings a project config may not make.
+// It is found in whatever directory mcphost runs in, so it must not be able
+// to send the user's API keys to another server.
+func validateProjectConfig(config *MCPConfig) error {
+	for name, settings := range config.Providers {
+		if settings == nil {
+			continue
+		}
+		at := []string{"providers", name}
+		if settings.BaseURL != "" {
+			return configErrorf(append(at, "baseUrl"), "can't be set by a project config, set it in the user config or with a flag")
+		}
+		if settings.APIKey != "" {
+			return configErrorf(append(at, "apiKey"), "can't be set by a project config, set it in the user config or with a flag")
+		}
+	}
+	return nil
+}
+
+// relativeTo joins relative paths to dir and leaves absolute paths and
+// paths starting with ~ alone
+func relativeTo(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// findProjectConfig looks for the project config in the current directory
+// and its parents, and returns "" when there is none
+func findProjectConfig() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("error getting current directory: %w", err)
+	}
+	for {
+		for _, name := range projectConfigNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// merge adds a config layer read from source on top of the config
+func (c *MCPConfig) merge(layer *MCPConfig, source string) {
+	if layer.Model != "" {
+		c.Model = layer.Model
+		c.sources["model"] = source
+	}
+	for name, settings := range layer.Providers {
+		if settings == nil {
+			continue
+		}
+		if settings.APIKey != "" {
+			c.provider(name).APIKey = settings.APIKey
+			c.sources["providers."+name+".apiKey"] = source
+		}
+		if settings.BaseURL != "" {
+			c.provider(name).BaseURL = settings.BaseURL
+			c.sources["providers."+name+".baseUrl"] = source
+		}
+	}
+	if layer.SystemPrompt != "" || layer.SystemPromptFile != "" {
+		c.SystemPrompt, c.SystemPromptFile = layer.SystemPrompt, layer.SystemPromptFile
+		delete(c.sources, "systemPrompt")
+		delete(c.sources, "systemPromptFile")
+		if layer.SystemPrompt != "" {
+			c.sources["systemPrompt"] = source
+		} else {
+			c.sources["systemPromptFile"] = source
+		}
+	}
+	if params := layer.Generation; params != nil {
+		if params.Temperature != nil {
+			c.generation().Temperature = params.Temperature
+			c.sources["generation.temperature"] = source
+		}
+		if params.TopP != nil {
+			c.generation().TopP = params.TopP
+			c.sources["generation.topP"] = source
+		}
+		if params.MaxTokens > 0 {
+			c.generation().MaxTokens = params.MaxTokens
+			c.sources["generation.maxTokens"] = source
+		}
+	}
+	if layer.MessageWindow > 0 {
+		c.MessageWindow = layer.MessageWindow
+		c.sources["messageWindow"] = source
+	}
+	if layer.ContextLength > 0 {
+		c.ContextLength = layer.ContextLength
+		c.sources["contextLength"] = source
+	}
+	if layer.MaxToolResultTokens > 0 {
+		c.MaxToolResultTokens = layer.MaxToolResultTokens
+		c.sources["maxToolResultTokens"] = source
+	}
+	if layer.ToolConcurrency > 0 {
+		c.ToolConcurrency = layer.ToolConcurrency
+		c.sources["toolConcurrency"] = source
+	}
+	if layer.ToolTimeout > 0 {
+		c.ToolTimeout = layer.ToolTimeout
+		c.sources["toolTimeout"] = source
+	}
+	if layer.MaxSteps > 0 {
+		c.MaxSteps = layer.MaxSteps
+		c.sources["maxSteps"] = source
+	}
+	if layer.MaxRepeatedToolCalls > 0 {
+		c.MaxRepeatedToolCalls = layer.MaxRepeatedToolCalls
+		c.sources["maxRepeatedToolCalls"] = source
+	}
+	if layer.Profile != "" {
+		c.Profile = layer.Profile
+		c.sources["profile"] = source
+	}
+	for name, profile := range layer.Profiles {
+		if c.Profiles == nil {
+			c.Profiles = make(map[string]*Profile)
+		}
+		c.Profiles[name] = profile
+		c.sources["profiles."+name] = source
+	}
+
+	for name, server := range layer.MCPServers {
+		key := "mcpServers." + name
+		_, inherited := c.MCPServers[name]
+		if server.Config.GetOptions().Disabled {
+			delete(c.MCPServers, name)
+			c.sources[key] = "disabled by " + source
+			continue
+		}
+		c.MCPServers[name] = server
+		if inherited {
+			c.sources[key] = fmt.Sprintf("%s (replaces %s)", source, c.sources[key])
+		} else {
+			c.sources[key] = source
+		}
+	}
+
+	if len(layer.Roots) > 0 {
+		c.Roots = layer.Roots
+		c.sources["roots"] = source
+	}
+
+	if layer.Secrets == nil {
+		return
+	}
+	if c.Secrets == nil {
+		c.Secrets = &SecretsConfig{}
+	}
+	if len(layer.Secrets.Dotenv) > 0 {
+		// The first file setting a variable wins, so the files of the later
+		// layer go first
+		sources := make([]string, len(layer.Secrets.Dotenv))
+		for i := range sources {
+			sources[i] = source
+		}
+		c.Secrets.Dotenv = append(append([]string{}, layer.Secrets.Dotenv...), c.Secrets.Dotenv...)
+		c.dotenvSources = append(sources, c.dotenvSources...)
+		for i, dotenvSource := range c.dotenvSources {
+			c.sources[fmt.Sprintf("secrets.dotenv[%d]", i)] = dotenvSource
+		}
+	}
+	for name, command := range layer.Secrets.Commands {
+		if c.Secrets.Commands == nil {
+			c.Secrets.Commands = make(map[string]string)
+		}
+		c.Secrets.Commands[name] = command
+		c.sources["secrets.commands."+name] = source
+	}
+}
+
+// applyFlags puts the flags given on the command line over the config
+func (c *MCPConfig) applyFlags(flags *pflag.FlagSet) {
+	changed := func(flag, key string) bool {
+		if !flags.Changed(flag) {
+			return false
+		}
+		c.sources[key] = "--" + flag + " flag"
+		return true
+	}
+
+	if changed("model", "model") {
+		c.Model = modelFlag
+	}
+	if changed("anthropic-api-key", "providers.anthropic.apiKey") {
+		c.provider(providerAnthropic).APIKey = anthropicAPIKey
+	}
+	if changed("anthropic-url", "providers.anthropic.baseUrl") {
+		c.provider(providerAnthropic).BaseURL = anthropicBaseURL
+	}
+	if changed("openai-api-key", "providers.openai.apiKey") {
+		c.provider(providerOpenAI).APIKey = openaiAPIKey
+	}
+	if changed("openai-url", "providers.openai.baseUrl") {
+		c.provider(providerOpenAI).BaseURL = openaiBaseURL
+	}
+	if changed("google-api-key", "providers.google.apiKey") {
+		c.provider(providerGoogle).APIKey = googleAPIKey
+	}
+	if flags.Changed("system-prompt") {
+		delete(c.sources, "systemPrompt")
+		changed("system-prompt", "systemPromptFile")
+		c.SystemPrompt, c.SystemPromptFile = "", systemPromptFile
+	}
+	if changed("temperature", "generation.temperature") {
+		c.generation().Temperature = &temperatureFlag
+	}
+	if changed("top-p", "generation.topP") {
+		c.generation().TopP = &topPFlag
+	}
+	if changed("max-tokens", "generation.maxTokens") {
+		c.generation().MaxTokens = maxTokensFlag
+	}
+	if changed("message-window", "messageWindow") {
+		c.MessageWindow = messageWindow
+	}
+	if changed("context-length", "contextLength") {
+		c.ContextLength = contextLength
+	}
+	if changed("max-tool-result-tokens", "maxToolResultTokens") {
+		c.MaxToolResultTokens = maxResultTokens
+	}
+	if changed("tool-concurrency", "toolConcurrency") {
+		c.ToolConcurrency = toolConcurrency
+	}
+	if changed("tool-timeout", "toolTimeout") {
+		c.ToolTimeout = toolTimeout
+	}
+	if changed("max-steps", "maxSteps") {
+		c.MaxSteps = maxSteps
+	}
+	if changed("max-repeated-tool-calls", "maxRepeatedToolCalls") {
+		c.MaxRepeatedToolCalls = maxRepeatedCalls
+	}
+	if changed("root", "roots") {
+		c.Roots = rootFlags
+	}
+}
+
+// generation returns the generation parameters, which are added to the
+// config if it has none
+func (c *MCPConfig) generation() *llm.GenerationParams {
+	if c.Generation == nil {
+		c.Generation = &llm.GenerationParams{}
+	}
+	return c.Generation
+}
+
+// printConfig writes the files the config was merged from, the config and
+// the source of each value
+func printConfig(w io.Writer, config *MCPConfig) error {
+	fmt.Fprintln(w, "Config files:")
+	if len(config.files) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+	for _, file := range config.files {
+		fmt.Fprintf(w, "  %s\n", file)
+	}
+
+	redacted := *config
+	redacted.Providers = make(map[string]*ProviderSettings, len(config.Providers))
+	for name, settings := range config.Providers {
+		if settings != nil && settings.APIKey != "" {
+			settings = &ProviderSettings{APIKey: redactValue(settings.APIKey), BaseURL: settings.BaseURL}
+		}
+		redacted.Providers[name] = settings
+	}
+	redacted.MCPServers = make(map[string]ServerConfigWrapper, len(config.MCPServers))
+	for name, server := range config.MCPServers {
+		redacted.MCPServers[name] = redactServer(server)
+	}
+	configData, err := json.MarshalIndent(redacted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+	fmt.Fprintf(w, "\n%s\n", configData)
+
+	if len(config.sources) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(config.sources))
+	for key := range config.sources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintln(w, "\nSources:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, key := range keys {
+		fmt.Fprintf(tw, "  %s\t%s\n", key, config.sources[key])
+	}
+	return tw.Flush()
+}
+
+// redactValue hides a secret, unless it is made of ${VAR} references,
+// which don't hold the secret itself
+func redactValue(value string) string {
+	if strings.Contains(value, "${") {
+		return value
+	}
+	return "[REDACTED]"
+}
+
+// redactServer hides the env and header values of a server
+func redactServer(server ServerConfigWrapper) ServerConfigWrapper {
+	redactHeaders := func(headers []string) []string {
+		if headers == nil {
+			return nil
+		}
+		redacted := make([]string, len(headers))
+		for i, header := range headers {
+			key, value, ok := strings.Cut(header, ":")
+			if !ok {
+				redacted[i] = header
+				continue
+			}
+			redacted[i] = key + ": " + redactValue(strings.TrimSpace(value))
+		}
+		return redacted
+	}
+	redactOAuth := func(options *OAuthOptions) *OAuthOptions {
+		if options == nil || options.ClientSecret == "" {
+			return options
+		}
+		redacted := *options
+		redacted.ClientSecret = redactValue(options.ClientSecret)
+		return &redacted
+	}
+
+	switch config := server.Config.(type) {
+	case STDIOServerConfig:
+		if config.Env != nil {
+			env := make(map[string]string, len(config.Env))
+			for key, value := range config.Env {
+				env[key] = redactValue(value)
+			}
+			config.Env = env
+		}
+		server.Config = config
+	case SSEServerConfig:
+		config.Headers = redactHeaders(config.Headers)
+		config.OAuth = redactOAuth(config.OAuth)
+		server.Config = config
+	case StreamableHTTPServerConfig:
+		config.Headers = redactHeaders(config.Headers)
+		config.OAuth = redactOAuth(config.OAuth)
+		server.Config = config
+	}
+	return server
+}
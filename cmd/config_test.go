@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// chdir changes the working directory for the rest of a test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// configFiles sets up a home directory with a user config and a project
+// directory with a project config, and runs the test in a directory of the
+// project. Configs that are empty aren't written. It returns the paths of
+// the configs.
+func configFiles(t *testing.T, user, project string) (string, string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	original := configFile
+	configFile = ""
+	t.Cleanup(func() { configFile = original })
+
+	userPath := filepath.Join(home, ".mcp.json")
+	if user != "" {
+		if err := os.WriteFile(userPath, []byte(user), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	projectDir := t.TempDir()
+	projectPath := filepath.Join(projectDir, ".mcphost.json")
+	if project != "" {
+		if err := os.WriteFile(projectPath, []byte(project), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	subDir := filepath.Join(projectDir, "src")
+	if err := os.Mkdir(subDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, subDir)
+	return userPath, projectPath
+}
+
+// parseFlags parses the --model and --root flags from args, and restores
+// them when the test ends
+func parseFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	t.Helper()
+	model, roots := modelFlag, rootFlags
+	t.Cleanup(func() { modelFlag, rootFlags = model, roots })
+
+	flags := pflag.NewFlagSet("mcphost", pflag.ContinueOnError)
+	flags.StringVar(&modelFlag, "model", "", "")
+	flags.StringArrayVar(&rootFlags, "root", nil, "")
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+func serverNames(config *MCPConfig) string {
+	names := make([]string, 0, len(config.MCPServers))
+	for name := range config.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+func TestLoadMCPConfigLayers(t *testing.T) {
+	const user = `{
+  "model": "anthropic:claude-3-5-sonnet-latest",
+  "roots": ["/srv/shared"],
+  "mcpServers": {
+    "fs": {"command": "fs-server"},
+    "git": {"command": "git-server"}
+  }
+}`
+
+	tests := []struct {
+		name    string
+		user    string
+		project string
+		trusted bool
+		args    []string
+
+		wantServers string
+		wantModel   string
+		// wantSources maps keys to their source, where "user" and
+		// "project" stand for the paths of the configs
+		wantSources map[string]string
+	}{
+		{
+			name:        "no configs",
+			wantSources: map[string]string{},
+		},
+		{
+			name:        "user",
+			user:        user,
+			wantServers: "fs git",
+			wantModel:   "anthropic:claude-3-5-sonnet-latest",
+			wantSources: map[string]string{
+				"model":          "user",
+				"roots":          "user",
+				"mcpServers.fs":  "user",
+				"mcpServers.git": "user",
+			},
+		},
+		{
+			name: "project",
+			user: user,
+			project: `{
+  "model": "openai:gpt-4o",
+  "roots": ["."],
+  "mcpServers": {
+    "fs": {"command": "fs-server", "args": ["--root", "."]},
+    "git": {"disabled": true},
+    "web": {"url": "https://example.com/sse"}
+  }
+}`,
+			trusted:     true,
+			wantServers: "fs web",
+			wantModel:   "openai:gpt-4o",
+			wantSources: map[string]string{
+				"model":          "project",
+				"roots":          "project",
+				"mcpServers.fs":  "project (replaces user)",
+				"mcpServers.git": "disabled by project",
+				"mcpServers.web": "project",
+			},
+		},
+		{
+			// The servers of a project config that isn't trusted are
+			// ignored, but it can still disable servers and make settings
+			name: "untrusted project",
+			user: user,
+			project: `{
+  "model": "openai:gpt-4o",
+  "roots": ["."],
+  "mcpServers": {
+    "git": {"disabled": true},
+    "web": {"url": "https://example.com/sse"}
+  }
+}`,
+			wantServers: "fs",
+			wantModel:   "openai:gpt-4o",
+			wantSources: map[string]string{
+				"model":          "project",
+				"roots":          "project",
+				"mcpServers.fs":  "user",
+				"mcpServers.git": "disabled by project",
+				"mcpServers.web": "ignored, project is not trusted",
+			},
+		},
+		{
+			name:        "flags",
+			user:        user,
+			project:     `{"model": "openai:gpt-4o", "roots": ["."]}`,
+			args:        []string{"--model", "ollama:qwen2.5:3b", "--root", "/srv/other"},
+			wantServers: "fs git",
+			wantModel:   "ollama:qwen2.5:3b",
+			wantSources: map[string]string{
+				"model":         "--model flag",
+				"roots":         "--root flag",
+				"mcpServers.fs": "user",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userPath, projectPath := configFiles(t, test.user, test.project)
+			flags := parseFlags(t, test.args...)
+			if test.trusted {
+				layer, err := readConfigFile(projectPath, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := trustProject(projectPath, layer.digest); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			config, err := loadMCPConfig(flags, "")
+			if err != nil {
+				t.Fatalf("loadMCPConfig() error = %v", err)
+			}
+
+			if got := serverNames(config); got != test.wantServers {
+				t.Errorf("servers = %q, want %q", got, test.wantServers)
+			}
+			if config.Model != test.wantModel {
+				t.Errorf("model = %q, want %q", config.Model, test.wantModel)
+			}
+			paths := strings.NewReplacer("user", userPath, "project", projectPath)
+			for key, want := range test.wantSources {
+				if want = paths.Replace(want); config.sources[key] != want {
+					t.Errorf("source of %s = %q, want %q", key, config.sources[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestProjectTrust(t *testing.T) {
+	const project = `{"mcpServers": {"web": {"url": "https://example.com/sse"}}}`
+
+	tests := []struct {
+		name string
+		// trusted is the project config that was trusted, if any
+		trusted     string
+		wantServers string
+	}{
+		{name: "not trusted"},
+		{name: "trusted", trusted: project, wantServers: "web"},
+		// The trust is lost once the config changes
+		{
+			name:    "changed",
+			trusted: `{"mcpServers": {"web": {"url": "https://example.org/sse"}}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, projectPath := configFiles(t, "", project)
+			if test.trusted != "" {
+				if err := trustProject(projectPath, configDigest([]byte(test.trusted))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			config, err := loadMCPConfig(parseFlags(t), "")
+			if err != nil {
+				t.Fatalf("loadMCPConfig() error = %v", err)
+			}
+			if got := serverNames(config); got != test.wantServers {
+				t.Errorf("servers = %q, want %q", got, test.wantServers)
+			}
+
+			// Only the user can read the trusted projects
+			if test.trusted != "" {
+				path, err := trustedProjectsPath()
+				if err != nil {
+					t.Fatal(err)
+				}
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if perm := info.Mode().Perm(); perm != 0o600 {
+					t.Errorf("trusted projects permissions = %o, want 600", perm)
+				}
+			}
+		})
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
-	"path/filepath"
+	"path"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/huh/spinner"
@@ -16,6 +18,7 @@ import (
 	"strings"
 
 	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcphost/pkg/history"
 	"github.com/mark3labs/mcphost/pkg/llm"
@@ -24,8 +27,11 @@ import (
 const (
 	transportStdio = "stdio"
 	transportSSE   = "sse"
+	transportHTTP  = "http"
 )
 
+const defaultStartupTimeout = 30 * time.Second
+
 var (
 	// Tokyo Night theme colors
 	tokyoPurple = lipgloss.Color("99")  // #9d7cd8
@@ -64,18 +70,219 @@ var (
 			PaddingRight(4)
 )
 
+// MCPConfig holds the settings of mcphost: the model and its providers,
+// the system prompt, the generation parameters and the MCP servers
 type MCPConfig struct {
+	// Model is the model to use as provider:model
+	Model string `json:"model,omitempty"`
+
+	// Providers hold the API keys and URLs of the providers by name
+	Providers map[string]*ProviderSettings `json:"providers,omitempty"`
+
+	// SystemPrompt is the system prompt, or SystemPromptFile a text file
+	// holding it
+	SystemPrompt     string `json:"systemPrompt,omitempty"`
+	SystemPromptFile string `json:"systemPromptFile,omitempty"`
+
+	// Generation tunes the responses of the model
+	Generation *llm.GenerationParams `json:"generation,omitempty"`
+
+	// MessageWindow is the most messages kept in context, on top of the
+	// limit of its tokens. There is no such limit by default.
+	MessageWindow int `json:"messageWindow,omitempty"`
+
+	// ContextLength is the number of tokens the context of the model holds,
+	// taken from a table of known models by default
+	ContextLength int `json:"contextLength,omitempty"`
+
+	// MaxToolResultTokens is the most tokens of a tool result, a quarter of
+	// what the context has room for by default
+	MaxToolResultTokens int `json:"maxToolResultTokens,omitempty"`
+
+	// ToolConcurrency is the number of tool calls of one turn that run at
+	// the same time
+	ToolConcurrency int `json:"toolConcurrency,omitempty"`
+
+	// ToolTimeout is the number of seconds a tool call may take, 300 by
+	// default. Servers and tools can set their own.
+	ToolTimeout int `json:"toolTimeout,omitempty"`
+
+	// MaxSteps is the most responses of the model for one prompt, 20 by
+	// default
+	MaxSteps int `json:"maxSteps,omitempty"`
+
+	// MaxRepeatedToolCalls is the number of identical tool calls in a row
+	// that stops a prompt, 3 by default
+	MaxRepeatedToolCalls int `json:"maxRepeatedToolCalls,omitempty"`
+
+	// Profiles bundle settings and servers by name, and Profile names the
+	// one used unless --profile picks another
+	Profile  string              `json:"profile,omitempty"`
+	Profiles map[string]*Profile `json:"profiles,omitempty"`
+
 	MCPServers map[string]ServerConfigWrapper `json:"mcpServers"`
+
+	// Roots are the directories shared with the servers as the workspace
+	Roots []string `json:"roots,omitempty"`
+
+	// Secrets are looked up for ${VAR} references in the server configs
+	Secrets *SecretsConfig `json:"secrets,omitempty"`
+
+	// files are the config files the config was merged from, and sources
+	// map the keys of the config to where their values came from
+	files         []string
+	sources       map[string]string
+	dotenvSources []string
+
+	// activeProfile is the profile applied over the config files
+	activeProfile string
+
+	// digest identifies the content of the file a layer was read from
+	digest string
+
+	resolver *secretResolver
 }
 
 type ServerConfig interface {
 	GetType() string
+	GetOptions() ServerOptions
+}
+
+// ServerOptions holds the settings shared by servers of every transport
+type ServerOptions struct {
+	// ToolPolicies maps tool names, or glob patterns matching them, to the
+	// policy for calling the tool: "auto", "ask" or "deny"
+	ToolPolicies map[string]string `json:"toolPolicies,omitempty"`
+
+	// AllowedTools limits the server to tools matching one of these glob
+	// patterns. All tools are allowed when it is empty.
+	AllowedTools []string `json:"allowedTools,omitempty"`
+
+	// DisabledTools hides tools matching any of these glob patterns
+	DisabledTools []string `json:"disabledTools,omitempty"`
+
+	// Sampling controls the completions the server may request from the
+	// model
+	Sampling *SamplingOptions `json:"sampling,omitempty"`
+
+	// StartupTimeout is the number of seconds the server has to start and
+	// initialize, 30 by default
+	StartupTimeout int `json:"startupTimeout,omitempty"`
+
+	// Lazy servers are started the first time they are used instead of
+	// when mcphost starts
+	Lazy bool `json:"lazy,omitempty"`
+
+	// Disabled servers are not started. A project config disables a server
+	// of the user config with "disabled": true.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Sequential servers get one tool call at a time, for servers that
+	// can't handle calls that overlap
+	Sequential bool `json:"sequential,omitempty"`
+
+	// ToolTimeout is the number of seconds the server's tool calls may
+	// take, and ToolTimeouts sets it for tool names or glob patterns
+	// matching them
+	ToolTimeout  int            `json:"toolTimeout,omitempty"`
+	ToolTimeouts map[string]int `json:"toolTimeouts,omitempty"`
+}
+
+// SamplingOptions limit the sampling requests of a server. Sampling is only
+// offered to servers that have them.
+type SamplingOptions struct {
+	// Approval is "ask" (the default), "auto" or "deny". Servers with "deny"
+	// are not offered sampling at all.
+	Approval string `json:"approval,omitempty"`
+
+	// MaxRequests is the number of requests the server may make while
+	// mcphost runs, 0 means no limit
+	MaxRequests int `json:"maxRequests,omitempty"`
+
+	// MaxTokens is the number of tokens the server's requests may use in
+	// total while mcphost runs, 0 means no limit
+	MaxTokens int `json:"maxTokens,omitempty"`
+}
+
+func (o ServerOptions) GetOptions() ServerOptions {
+	return o
+}
+
+func (o ServerOptions) startupTimeout() time.Duration {
+	if o.StartupTimeout > 0 {
+		return time.Duration(o.StartupTimeout) * time.Second
+	}
+	return defaultStartupTimeout
+}
+
+// toolEnabled reports whether the allowed and disabled tool lists let the
+// model see and call a tool
+func (o ServerOptions) toolEnabled(toolName string) bool {
+	for _, pattern := range o.DisabledTools {
+		if matched, _ := path.Match(pattern, toolName); matched {
+			return false
+		}
+	}
+	if len(o.AllowedTools) == 0 {
+		return true
+	}
+	for _, pattern := range o.AllowedTools {
+		if matched, _ := path.Match(pattern, toolName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// filterTools drops the tools the server's options don't allow
+func filterTools(options ServerOptions, tools []mcp.Tool) []mcp.Tool {
+	var filtered []mcp.Tool
+	for _, tool := range tools {
+		if options.toolEnabled(tool.Name) {
+			filtered = append(filtered, tool)
+		}
+	}
+	return filtered
+}
+
+// toolTimeout returns how long a call of a tool may take. The timeout of
+// the tool wins over the timeout of its server, which wins over the global
+// one.
+func (c *MCPConfig) toolTimeout(serverName, toolName string) time.Duration {
+	options := c.serverOptions(serverName)
+	if seconds, ok := matchTool(options.ToolTimeouts, toolName); ok && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if options.ToolTimeout > 0 {
+		return time.Duration(options.ToolTimeout) * time.Second
+	}
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return time.Duration(toolTimeout) * time.Second
+}
+
+// serverOptions returns the options of a configured server
+func (c *MCPConfig) serverOptions(serverName string) ServerOptions {
+	if server, ok := c.serverConfig(serverName); ok {
+		return server.Config.GetOptions()
+	}
+	return ServerOptions{}
+}
+
+// serverConfig returns the config of a server, and whether it is configured
+func (c *MCPConfig) serverConfig(serverName string) (ServerConfigWrapper, bool) {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	server, ok := c.MCPServers[serverName]
+	return server, ok && server.Config != nil
 }
 
 type STDIOServerConfig struct {
-	Command string            `json:"command"`
-	Args    []string          `json:"args"`
-	Env     map[string]string `json:"env,omitempty"`
+	Transport string            `json:"transport,omitempty"`
+	Command   string            `json:"command"`
+	Args      []string          `json:"args"`
+	Env       map[string]string `json:"env,omitempty"`
+	ServerOptions
 }
 
 func (s STDIOServerConfig) GetType() string {
@@ -83,35 +290,64 @@ func (s STDIOServerConfig) GetType() string {
 }
 
 type SSEServerConfig struct {
-	Url     string   `json:"url"`
-	Headers []string `json:"headers,omitempty"`
+	Transport string        `json:"transport,omitempty"`
+	Url       string        `json:"url"`
+	Headers   []string      `json:"headers,omitempty"`
+	OAuth     *OAuthOptions `json:"oauth,omitempty"`
+	ServerOptions
 }
 
 func (s SSEServerConfig) GetType() string {
 	return transportSSE
 }
 
+// StreamableHTTPServerConfig is a server using the Streamable HTTP
+// transport, selected with "transport": "http"
+type StreamableHTTPServerConfig struct {
+	Transport string        `json:"transport"`
+	Url       string        `json:"url"`
+	Headers   []string      `json:"headers,omitempty"`
+	OAuth     *OAuthOptions `json:"oauth,omitempty"`
+	ServerOptions
+}
+
+func (s StreamableHTTPServerConfig) GetType() string {
+	return transportHTTP
+}
+
 type ServerConfigWrapper struct {
 	Config ServerConfig
 }
 
 func (w *ServerConfigWrapper) UnmarshalJSON(data []byte) error {
 	var typeField struct {
-		Url string `json:"url"`
+		Transport string `json:"transport"`
+		Url       string `json:"url"`
 	}
 
 	if err := json.Unmarshal(data, &typeField); err != nil {
 		return err
 	}
-	if typeField.Url != "" {
-		// If the URL field is present, treat it as an SSE server
+
+	transport, err := serverTransport(typeField.Transport, typeField.Url)
+	if err != nil {
+		return err
+	}
+
+	switch transport {
+	case transportSSE:
 		var sse SSEServerConfig
 		if err := json.Unmarshal(data, &sse); err != nil {
 			return err
 		}
 		w.Config = sse
-	} else {
-		// Otherwise, treat it as a STDIOServerConfig
+	case transportHTTP:
+		var httpConfig StreamableHTTPServerConfig
+		if err := json.Unmarshal(data, &httpConfig); err != nil {
+			return err
+		}
+		w.Config = httpConfig
+	default:
 		var stdio STDIOServerConfig
 		if err := json.Unmarshal(data, &stdio); err != nil {
 			return err
@@ -121,194 +357,414 @@ func (w *ServerConfigWrapper) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
-func (w ServerConfigWrapper) MarshalJSON() ([]byte, error) {
-	return json.Marshal(w.Config)
-}
-
-func mcpToolsToAnthropicTools(
-	serverName string,
-	mcpTools []mcp.Tool,
-) []llm.Tool {
-	anthropicTools := make([]llm.Tool, len(mcpTools))
 
-	for i, tool := range mcpTools {
-		namespacedName := fmt.Sprintf("%s__%s", serverName, tool.Name)
+// serverTransport returns the transport of a server. Without an explicit
+// transport, servers with a URL use SSE.
+func serverTransport(transport, url string) (string, error) {
+	if transport == "" {
+		transport = transportStdio
+		if url != "" {
+			transport = transportSSE
+		}
+	}
 
-		anthropicTools[i] = llm.Tool{
-			Name:        namespacedName,
-			Description: tool.Description,
-			InputSchema: llm.Schema{
-				Type:       tool.InputSchema.Type,
-				Properties: tool.InputSchema.Properties,
-				Required:   tool.InputSchema.Required,
-			},
+	switch transport {
+	case transportSSE, transportHTTP:
+		if url == "" {
+			return "", fmt.Errorf("url is required for the %s transport", transport)
 		}
+	case transportStdio:
+	default:
+		return "", fmt.Errorf(
+			"unknown transport %q, expected %s, %s or %s",
+			transport, transportStdio, transportSSE, transportHTTP,
+		)
 	}
+	return transport, nil
+}
 
-	return anthropicTools
+func (w ServerConfigWrapper) MarshalJSON() ([]byte, error) {
+	return json.Marshal(w.Config)
 }
 
-func loadMCPConfig() (*MCPConfig, error) {
-	var configPath string
-	if configFile != "" {
-		configPath = configFile
-	} else {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("error getting home directory: %w", err)
+func validateMCPConfig(config *MCPConfig) error {
+	if err := validateSettings(config); err != nil {
+		return err
+	}
+	if config.Secrets != nil {
+		for name, command := range config.Secrets.Commands {
+			at := []string{"secrets", "commands", name}
+			if !validVarName(name) {
+				return configErrorf(at, "invalid variable name %q", name)
+			}
+			if strings.TrimSpace(command) == "" {
+				return configErrorf(at, "empty command")
+			}
 		}
-		configPath = filepath.Join(homeDir, ".mcp.json")
 	}
-
-	// Check if config file exists
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		// Create default config
-		defaultConfig := MCPConfig{
-			MCPServers: make(map[string]ServerConfigWrapper),
+	for name, server := range config.MCPServers {
+		at := func(keys ...string) []string {
+			return append([]string{"mcpServers", name}, keys...)
 		}
-
-		// Create the file with default config
-		configData, err := json.MarshalIndent(defaultConfig, "", "  ")
-		if err != nil {
-			return nil, fmt.Errorf("error creating default config: %w", err)
+		if server.Config.GetOptions().Disabled {
+			continue
 		}
-
-		if err := os.WriteFile(configPath, configData, 0644); err != nil {
-			return nil, fmt.Errorf("error writing default config file: %w", err)
+		if name == hostServerName {
+			return configErrorf(at(), "server name %s is reserved", name)
+		}
+		if err := checkServerVars(server); err != nil {
+			return &configError{path: at(), err: err}
+		}
+		options := server.Config.GetOptions()
+		for key, patterns := range map[string][]string{
+			"allowedTools":  options.AllowedTools,
+			"disabledTools": options.DisabledTools,
+		} {
+			for _, pattern := range patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return configErrorf(at(key), "invalid tool pattern %q: %w", pattern, err)
+				}
+			}
+		}
+		if sampling := options.Sampling; sampling != nil {
+			switch sampling.Approval {
+			case "", policyAuto, policyAsk, policyDeny:
+			default:
+				return configErrorf(
+					at("sampling", "approval"),
+					"invalid sampling approval %q, expected %s, %s or %s",
+					sampling.Approval, policyAuto, policyAsk, policyDeny,
+				)
+			}
+			if sampling.MaxRequests < 0 || sampling.MaxTokens < 0 {
+				return configErrorf(at("sampling"), "sampling limits must not be negative")
+			}
+		}
+		if _, oauthOptions, ok := remoteServer(server); ok && oauthOptions != nil {
+			if err := validateOAuthOptions(oauthOptions); err != nil {
+				return &configError{path: at("oauth"), err: err}
+			}
+		}
+		if options.StartupTimeout < 0 {
+			return configErrorf(at("startupTimeout"), "startup timeout must not be negative")
+		}
+		if options.ToolTimeout < 0 {
+			return configErrorf(at("toolTimeout"), "tool timeout must not be negative")
+		}
+		for pattern, seconds := range options.ToolTimeouts {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return configErrorf(at("toolTimeouts", pattern), "invalid tool pattern %q: %w", pattern, err)
+			}
+			if seconds <= 0 {
+				return configErrorf(at("toolTimeouts", pattern), "tool timeout must be positive")
+			}
+		}
+		for pattern, policy := range options.ToolPolicies {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return configErrorf(at("toolPolicies", pattern), "invalid tool pattern %q: %w", pattern, err)
+			}
+			switch policy {
+			case policyAuto, policyAsk, policyDeny:
+			default:
+				return configErrorf(
+					at("toolPolicies", pattern),
+					"invalid policy %q, expected %s, %s or %s",
+					policy, policyAuto, policyAsk, policyDeny,
+				)
+			}
 		}
-
-		log.Info("Created default config file", "path", configPath)
-		return &defaultConfig, nil
 	}
+	return nil
+}
 
-	// Read existing config
-	configData, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf(
-			"error reading config file %s: %w",
-			configPath,
-			err,
-		)
+// checkServerVars checks the ${VAR} references in the config of a server
+func checkServerVars(server ServerConfigWrapper) error {
+	var values []string
+	switch config := server.Config.(type) {
+	case STDIOServerConfig:
+		values = append([]string{config.Command}, config.Args...)
+		for _, value := range config.Env {
+			values = append(values, value)
+		}
+	case SSEServerConfig:
+		values = append([]string{config.Url}, config.Headers...)
+	case StreamableHTTPServerConfig:
+		values = append([]string{config.Url}, config.Headers...)
 	}
-
-	var config MCPConfig
-	if err := json.Unmarshal(configData, &config); err != nil {
-		return nil, fmt.Errorf("error parsing config file: %w", err)
+	if _, oauthOptions, ok := remoteServer(server); ok && oauthOptions != nil {
+		values = append(values, oauthOptions.ClientID, oauthOptions.ClientSecret)
 	}
 
-	return &config, nil
+	for _, value := range values {
+		if err := checkVars(value); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
+// createMCPClients starts servers of the config in parallel. Servers that
+// fail to start are kept as failed and tried again by their supervisor, and
+// lazy servers are left for their first use.
 func createMCPClients(
 	config *MCPConfig,
-) (map[string]mcpclient.MCPClient, error) {
+	servers map[string]ServerConfigWrapper,
+	sampler *samplingHandler,
+	roots *rootsHandler,
+) map[string]mcpclient.MCPClient {
 	clients := make(map[string]mcpclient.MCPClient)
-
-	for name, server := range config.MCPServers {
-		var client mcpclient.MCPClient
-		var err error
-
-		if server.Config.GetType() == transportSSE {
-			sseConfig := server.Config.(SSEServerConfig)
-
-			options := []mcpclient.ClientOption{}
-
-			if sseConfig.Headers != nil {
-				// Parse headers from the config
-				headers := make(map[string]string)
-				for _, header := range sseConfig.Headers {
-					parts := strings.SplitN(header, ":", 2)
-					if len(parts) == 2 {
-						key := strings.TrimSpace(parts[0])
-						value := strings.TrimSpace(parts[1])
-						headers[key] = value
-					}
-				}
-				options = append(options, mcpclient.WithHeaders(headers))
-			}
-
-			client, err = mcpclient.NewSSEMCPClient(
-				sseConfig.Url,
-				options...,
-			)
-			if err == nil {
-				err = client.(*mcpclient.SSEMCPClient).Start(context.Background())
+	secrets := config.secrets()
+
+	var wg sync.WaitGroup
+	for name, server := range servers {
+		name, server := name, server
+		options := server.Config.GetOptions()
+		var auth *serverAuth
+		client := newSupervisedClient(name, func(ctx context.Context) (*mcpclient.Client, error) {
+			// Secrets are looked up when the server starts, so that lazy
+			// servers only ask for theirs when they are used
+			resolved, err := secrets.resolveServer(server)
+			if err != nil {
+				return nil, err
 			}
-		} else {
-			stdioConfig := server.Config.(STDIOServerConfig)
-			var env []string
-			for k, v := range stdioConfig.Env {
-				env = append(env, fmt.Sprintf("%s=%s", k, v))
+			if serverURL, oauthOptions, ok := remoteServer(resolved); ok && auth == nil {
+				auth = newServerAuth(name, serverURL, oauthOptions)
 			}
-			client, err = mcpclient.NewStdioMCPClient(
-				stdioConfig.Command,
-				env,
-				stdioConfig.Args...)
+			return connectMCPServer(ctx, name, resolved, auth, sampler, roots)
+		})
+		clients[name] = client
+
+		if options.Lazy {
+			log.Info("Server starts on first use", "name", name)
+			continue
 		}
-		if err != nil {
-			for _, c := range clients {
-				c.Close()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log.Info("Initializing server...", "name", name)
+			if err := client.start(); err != nil {
+				log.Warn("Server unavailable", "name", name, "error", err)
+				return
 			}
-			return nil, fmt.Errorf(
-				"failed to create MCP client for %s: %w",
-				name,
-				err,
-			)
+			log.Info("Server connected", "name", name)
+		}()
+	}
+	wg.Wait()
+
+	return clients
+}
+
+// parseHeaders turns "Key: value" headers from the config into a map
+func parseHeaders(headers []string) map[string]string {
+	parsed := make(map[string]string)
+	for _, header := range headers {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) == 2 {
+			key := strings.TrimSpace(parts[0])
+			value := strings.TrimSpace(parts[1])
+			parsed[key] = value
 		}
+	}
+	return parsed
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+// remoteServer returns the URL and OAuth options of servers that are
+// reached over HTTP
+func remoteServer(server ServerConfigWrapper) (string, *OAuthOptions, bool) {
+	switch config := server.Config.(type) {
+	case SSEServerConfig:
+		return config.Url, config.OAuth, true
+	case StreamableHTTPServerConfig:
+		return config.Url, config.OAuth, true
+	}
+	return "", nil, false
+}
 
-		log.Info("Initializing server...", "name", name)
-		initRequest := mcp.InitializeRequest{}
-		initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-		initRequest.Params.ClientInfo = mcp.Implementation{
-			Name:    "mcphost",
-			Version: "0.1.0",
+// connectMCPServer starts a server, or connects to it, and initializes it.
+// The connection is closed when ctx is done. Remote servers that ask for
+// authorization are authorized first; the startup timeout doesn't count the
+// time the user takes for that.
+func connectMCPServer(
+	ctx context.Context,
+	name string,
+	server ServerConfigWrapper,
+	auth *serverAuth,
+	sampler *samplingHandler,
+	roots *rootsHandler,
+) (*mcpclient.Client, error) {
+	timeout := server.Config.GetOptions().startupTimeout()
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := time.AfterFunc(timeout, func() {
+		cancel(fmt.Errorf("server %s did not start within %s", name, timeout))
+	})
+
+	client, err := startMCPClient(ctx, name, server, auth, sampler, roots)
+	if err != nil && auth != nil && auth.required(err) && timer.Stop() {
+		if err := auth.authorize(ctx); err != nil {
+			cancel(err)
+			return nil, fmt.Errorf("error authorizing server %s: %w", name, err)
 		}
-		initRequest.Params.Capabilities = mcp.ClientCapabilities{}
+		timer.Reset(timeout)
+		client, err = startMCPClient(ctx, name, server, auth, sampler, roots)
+	}
 
-		_, err = client.Initialize(ctx, initRequest)
-		if err != nil {
+	if !timer.Stop() {
+		if err == nil {
 			client.Close()
-			for _, c := range clients {
-				c.Close()
-			}
-			return nil, fmt.Errorf(
-				"failed to initialize MCP client for %s: %w",
-				name,
-				err,
-			)
 		}
+		err = context.Cause(ctx)
+	}
+	if err != nil {
+		cancel(err)
+		return nil, err
+	}
+	return client, nil
+}
 
-		clients[name] = client
+func startMCPClient(
+	ctx context.Context,
+	name string,
+	server ServerConfigWrapper,
+	auth *serverAuth,
+	sampler *samplingHandler,
+	roots *rootsHandler,
+) (*mcpclient.Client, error) {
+	var mcpTransport transport.Interface
+	var err error
+
+	switch server.Config.GetType() {
+	case transportSSE:
+		sseConfig := server.Config.(SSEServerConfig)
+
+		options := []transport.ClientOption{
+			transport.WithHTTPClient(auth.httpClient(http.DefaultTransport)),
+		}
+
+		if sseConfig.Headers != nil {
+			options = append(options, transport.WithHeaders(parseHeaders(sseConfig.Headers)))
+		}
+		if oauthConfig := auth.transportConfig(); oauthConfig != nil {
+			options = append(options, transport.WithOAuth(*oauthConfig))
+		}
+
+		mcpTransport, err = transport.NewSSE(
+			sseConfig.Url,
+			options...,
+		)
+		if err != nil {
+			return nil, err
+		}
+	case transportHTTP:
+		httpConfig := server.Config.(StreamableHTTPServerConfig)
+
+		options := []transport.StreamableHTTPCOption{
+			transport.WithHTTPBasicClient(auth.httpClient(
+				&resumingTransport{base: http.DefaultTransport},
+			)),
+			// Listen for notifications and requests outside of responses
+			transport.WithContinuousListening(),
+			transport.WithHTTPLogger(transportLogger{name: name}),
+		}
+
+		if httpConfig.Headers != nil {
+			options = append(options, transport.WithHTTPHeaders(parseHeaders(httpConfig.Headers)))
+		}
+		if oauthConfig := auth.transportConfig(); oauthConfig != nil {
+			options = append(options, transport.WithHTTPOAuth(*oauthConfig))
+		}
+
+		mcpTransport, err = transport.NewStreamableHTTP(
+			httpConfig.Url,
+			options...,
+		)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		stdioConfig := server.Config.(STDIOServerConfig)
+		var env []string
+		for k, v := range stdioConfig.Env {
+			env = append(env, fmt.Sprintf("%s=%s", k, v))
+		}
+		mcpTransport = transport.NewStdioWithOptions(
+			stdioConfig.Command,
+			env,
+			stdioConfig.Args,
+			transport.WithCommandLogger(transportLogger{name: name}))
 	}
 
-	return clients, nil
+	clientOptions := []mcpclient.ClientOption{
+		mcpclient.WithRootsHandler(roots),
+	}
+	if sampler.enabled(name) {
+		clientOptions = append(clientOptions,
+			mcpclient.WithSamplingHandler(sampler.forServer(name)))
+	}
+	client := mcpclient.NewClient(mcpTransport, clientOptions...)
+	if err := client.Start(ctx); err != nil {
+		return nil, err
+	}
+
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{
+		Name:    "mcphost",
+		Version: "0.1.0",
+	}
+	initRequest.Params.Capabilities = mcp.ClientCapabilities{}
+
+	if _, err := client.Initialize(ctx, initRequest); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
 }
 
 func handleSlashCommand(
 	prompt string,
 	mcpConfig *MCPConfig,
 	mcpClients map[string]mcpclient.MCPClient,
-	messages interface{},
+	chat *chatSession,
+	roots *rootsHandler,
 ) (bool, error) {
 	if !strings.HasPrefix(prompt, "/") {
 		return false, nil
 	}
 
-	switch strings.ToLower(strings.TrimSpace(prompt)) {
+	fields := strings.Fields(prompt)
+	args := fields[1:]
+
+	switch strings.ToLower(fields[0]) {
 	case "/tools":
-		handleToolsCommand(mcpClients)
+		handleToolsCommand(mcpConfig, mcpClients)
 		return true, nil
 	case "/help":
 		handleHelpCommand()
 		return true, nil
 	case "/history":
-		handleHistoryCommand(messages.([]history.HistoryMessage))
+		handleHistoryCommand(chat.current.Messages)
+		return true, nil
+	case "/prompts":
+		handlePromptsCommand(mcpClients)
+		return true, nil
+	case "/resources":
+		handleResourcesCommand(mcpClients)
+		return true, nil
+	case "/roots":
+		handleRootsCommand(roots, mcpClients, args)
 		return true, nil
 	case "/servers":
-		handleServersCommand(mcpConfig)
+		handleServersCommand(mcpConfig, mcpClients)
+		return true, nil
+	case "/save":
+		handleSaveCommand(chat, args)
+		return true, nil
+	case "/load":
+		handleLoadCommand(chat, args)
+		return true, nil
+	case "/sessions":
+		handleSessionsCommand(chat)
 		return true, nil
 	case "/quit":
 		fmt.Println("\nGoodbye!")
@@ -335,10 +791,19 @@ func handleHelpCommand() {
 	markdown.WriteString("The following commands are available:\n\n")
 	markdown.WriteString("- **/help**: Show this help message\n")
 	markdown.WriteString("- **/tools**: List all available tools\n")
+	markdown.WriteString("- **/resources**: List the resources of all servers\n")
+	markdown.WriteString("- **/prompts**: List the prompts of all servers\n")
+	markdown.WriteString("- **/server:prompt [name=value ...]**: Run a prompt of a server\n")
 	markdown.WriteString("- **/servers**: List configured MCP servers\n")
+	markdown.WriteString("- **/roots [add|remove path]**: List or change the directories shared with the servers\n")
+	markdown.WriteString("- **/profile [name]**: List the profiles or switch to another one\n")
 	markdown.WriteString("- **/history**: Display conversation history\n")
+	markdown.WriteString("- **/sessions**: List saved sessions\n")
+	markdown.WriteString("- **/save [id]**: Save the current session, optionally under a new id\n")
+	markdown.WriteString("- **/load id**: Load a saved session\n")
 	markdown.WriteString("- **/quit**: Exit the application\n")
-	markdown.WriteString("\nYou can also press Ctrl+C at any time to quit.\n")
+	markdown.WriteString("\nMention a resource as `@server:uri` to attach it to your prompt.\n")
+	markdown.WriteString("Press Ctrl+C to cancel a running response or tool call, or to quit at the prompt.\n")
 
 	markdown.WriteString("\n## Available Models\n\n")
 	markdown.WriteString("Specify models using the --model or -m flag:\n\n")
@@ -364,7 +829,7 @@ func handleHelpCommand() {
 	fmt.Print(rendered)
 }
 
-func handleServersCommand(config *MCPConfig) {
+func handleServersCommand(config *MCPConfig, mcpClients map[string]mcpclient.MCPClient) {
 	if err := updateRenderer(); err != nil {
 		fmt.Printf(
 			"\n%s\n",
@@ -381,13 +846,27 @@ func handleServersCommand(config *MCPConfig) {
 			for name, server := range config.MCPServers {
 				markdown.WriteString(fmt.Sprintf("# %s\n\n", name))
 
-				if server.Config.GetType() == transportSSE {
-					sseConfig := server.Config.(SSEServerConfig)
+				if client, ok := mcpClients[name].(*supervisedClient); ok {
+					writeServerStatus(&markdown, client.status())
+				}
+
+				var url string
+				var headers []string
+				switch config := server.Config.(type) {
+				case SSEServerConfig:
+					url, headers = config.Url, config.Headers
+				case StreamableHTTPServerConfig:
+					url, headers = config.Url, config.Headers
+				}
+
+				if server.Config.GetType() != transportStdio {
+					markdown.WriteString("*Transport*\n")
+					markdown.WriteString(fmt.Sprintf("`%s`\n\n", server.Config.GetType()))
 					markdown.WriteString("*Url*\n")
-					markdown.WriteString(fmt.Sprintf("`%s`\n\n", sseConfig.Url))
+					markdown.WriteString(fmt.Sprintf("`%s`\n\n", url))
 					markdown.WriteString("*headers*\n")
-					if sseConfig.Headers != nil {
-						for _, header := range sseConfig.Headers {
+					if headers != nil {
+						for _, header := range headers {
 							parts := strings.SplitN(header, ":", 2)
 							if len(parts) == 2 {
 								key := strings.TrimSpace(parts[0])
@@ -439,7 +918,30 @@ func handleServersCommand(config *MCPConfig) {
 	fmt.Print("\n" + containerStyle.Render(rendered) + "\n")
 }
 
-func handleToolsCommand(mcpClients map[string]mcpclient.MCPClient) {
+// writeServerStatus adds the live status of a server to the /servers output
+func writeServerStatus(markdown *strings.Builder, status serverStatus) {
+	markdown.WriteString("*Status*\n")
+	switch status.State {
+	case serverNotStarted:
+		markdown.WriteString("`not started`, starts on first use")
+	case serverConnected:
+		uptime := time.Since(status.Since).Round(time.Second)
+		markdown.WriteString(fmt.Sprintf("`connected` for %s", uptime))
+	default:
+		markdown.WriteString(fmt.Sprintf("`%s` since %s", status.State, status.Since.Format(time.Kitchen)))
+	}
+	if status.Restarts > 0 {
+		markdown.WriteString(fmt.Sprintf(" (restarts: %d)", status.Restarts))
+	}
+	markdown.WriteString("\n\n")
+
+	if status.LastErr != nil {
+		markdown.WriteString("*Last error*\n")
+		markdown.WriteString(fmt.Sprintf("`%s`\n\n", status.LastErr))
+	}
+}
+
+func handleToolsCommand(mcpConfig *MCPConfig, mcpClients map[string]mcpclient.MCPClient) {
 	// Get terminal width for proper wrapping
 	width := getTerminalWidth()
 
@@ -481,7 +983,7 @@ func handleToolsCommand(mcpClients map[string]mcpclient.MCPClient) {
 
 			var tools []mcp.Tool
 			if toolsResult != nil {
-				tools = toolsResult.Tools
+				tools = filterTools(mcpConfig.serverOptions(serverName), toolsResult.Tools)
 			}
 
 			results[serverName] = serverTools{
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestFilterTools(t *testing.T) {
+	tools := []mcp.Tool{
+		mcp.NewTool("read_file"),
+		mcp.NewTool("read_dir"),
+		mcp.NewTool("write_file"),
+		mcp.NewTool("delete_file"),
+	}
+
+	tests := []struct {
+		name    string
+		options ServerOptions
+		want    string
+	}{
+		{name: "no lists", want: "read_file read_dir write_file delete_file"},
+		{
+			name:    "allowed",
+			options: ServerOptions{AllowedTools: []string{"read_*"}},
+			want:    "read_file read_dir",
+		},
+		{
+			name:    "disabled",
+			options: ServerOptions{DisabledTools: []string{"delete_file", "write_*"}},
+			want:    "read_file read_dir",
+		},
+		{
+			// A disabled tool stays hidden even if it is allowed
+			name: "allowed and disabled",
+			options: ServerOptions{
+				AllowedTools:  []string{"*_file"},
+				DisabledTools: []string{"delete_*"},
+			},
+			want: "read_file write_file",
+		},
+		{
+			name:    "nothing allowed",
+			options: ServerOptions{AllowedTools: []string{"exec"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var names []string
+			for _, tool := range filterTools(test.options, tools) {
+				names = append(names, tool.Name)
+			}
+			if got := strings.Join(names, " "); got != test.want {
+				t.Errorf("filterTools() = %q, want %q", got, test.want)
+			}
+			for _, tool := range tools {
+				want := strings.Contains(" "+test.want+" ", " "+tool.Name+" ")
+				if got := test.options.toolEnabled(tool.Name); got != want {
+					t.Errorf("toolEnabled(%q) = %v, want %v", tool.Name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// logLevelOff turns off the log messages of the servers
+const logLevelOff = "off"
+
+// validateLogLevel checks the level given with --mcp-log-level
+func validateLogLevel(level string) error {
+	switch mcp.LoggingLevel(level) {
+	case mcp.LoggingLevelDebug, mcp.LoggingLevelInfo, mcp.LoggingLevelNotice,
+		mcp.LoggingLevelWarning, mcp.LoggingLevelError, mcp.LoggingLevelCritical,
+		mcp.LoggingLevelAlert, mcp.LoggingLevelEmergency, logLevelOff:
+		return nil
+	}
+	return fmt.Errorf("invalid MCP log level %q", level)
+}
+
+// watchNotifications handles the notifications of every server. Tools are
+// loaded again when a server's tools change or the server connects again, and
+// log messages at or above the --mcp-log-level are shown.
+func watchNotifications(
+	mcpClients map[string]mcpclient.MCPClient,
+	tools *toolSet,
+) {
+	for name, mcpClient := range mcpClients {
+		serverName, client := name, mcpClient
+
+		client.OnNotification(func(notification mcp.JSONRPCNotification) {
+			switch notification.Method {
+			case mcp.MethodNotificationToolsListChanged:
+				log.Info("Tools changed", "server", serverName)
+				// Notifications arrive on the goroutine that reads the
+				// server's responses, so the tools can't be listed here
+				go func() {
+					if err := tools.load(context.Background(), serverName, client); err != nil {
+						log.Error("Error fetching tools", "server", serverName, "error", err)
+					}
+				}()
+
+			case "notifications/message":
+				logServerMessage(serverName, notification)
+
+			default:
+				log.Debug("Notification", "server", serverName, "method", notification.Method)
+			}
+		})
+
+		setServerLogLevel(serverName, client)
+
+		// A server that starts late or reconnects starts over, with its
+		// tools possibly changed and the log level not set
+		if sc, ok := client.(*supervisedClient); ok {
+			sc.onConnect(func() {
+				if err := tools.load(context.Background(), serverName, client); err != nil {
+					log.Error("Error fetching tools", "server", serverName, "error", err)
+				}
+				setServerLogLevel(serverName, client)
+			})
+		}
+	}
+}
+
+// setServerLogLevel asks a server to send log messages at or above the
+// --mcp-log-level
+func setServerLogLevel(serverName string, client mcpclient.MCPClient) {
+	if mcpLogLevel == logLevelOff {
+		return
+	}
+	if c, ok := client.(interface {
+		GetServerCapabilities() mcp.ServerCapabilities
+	}); ok && c.GetServerCapabilities().Logging == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request := mcp.SetLevelRequest{}
+	request.Params.Level = mcp.LoggingLevel(mcpLogLevel)
+	if err := client.SetLevel(ctx, request); err != nil {
+		log.Debug("Error setting log level", "server", serverName, "error", err)
+	}
+}
+
+func logServerMessage(serverName string, notification mcp.JSONRPCNotification) {
+	if mcpLogLevel == logLevelOff {
+		return
+	}
+
+	var params mcp.LoggingMessageNotificationParams
+	fields := notification.Params.AdditionalFields
+	if data, err := json.Marshal(fields); err == nil {
+		_ = json.Unmarshal(data, &params)
+	}
+
+	// Servers don't have to honor the level that was set
+	if !params.Level.ShouldSendTo(mcp.LoggingLevel(mcpLogLevel)) {
+		return
+	}
+
+	message, ok := params.Data.(string)
+	if !ok {
+		data, _ := json.Marshal(params.Data)
+		message = string(data)
+	}
+
+	keyvals := []interface{}{"server", serverName}
+	if params.Logger != "" {
+		keyvals = append(keyvals, "logger", params.Logger)
+	}
+
+	switch params.Level {
+	case mcp.LoggingLevelDebug, mcp.LoggingLevelInfo, mcp.LoggingLevelNotice:
+		log.Info(message, keyvals...)
+	case mcp.LoggingLevelWarning:
+		log.Warn(message, keyvals...)
+	default:
+		log.Error(message, keyvals...)
+	}
+}
@@ -0,0 +1,541 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+)
+
+const (
+	oauthFlowBrowser = "browser"
+	oauthFlowDevice  = "device"
+
+	defaultRedirectURI = "http://localhost:8085/oauth/callback"
+	authorizeTimeout   = 5 * time.Minute
+)
+
+// OAuthOptions configure the authorization of a remote server. Servers
+// without them are authorized too when they answer with 401 Unauthorized.
+type OAuthOptions struct {
+	// ClientID is registered with the authorization server when empty
+	ClientID     string `json:"clientId,omitempty"`
+	ClientSecret string `json:"clientSecret,omitempty"`
+
+	Scopes []string `json:"scopes,omitempty"`
+
+	// RedirectURI receives the result of the browser flow, it must be a
+	// localhost URL
+	RedirectURI string `json:"redirectUri,omitempty"`
+
+	// Flow is "browser" (the default) or "device"
+	Flow string `json:"flow,omitempty"`
+
+	// AuthServerMetadataURL skips discovering the authorization server
+	AuthServerMetadataURL string `json:"authServerMetadataUrl,omitempty"`
+}
+
+func validateOAuthOptions(options *OAuthOptions) error {
+	switch options.Flow {
+	case "", oauthFlowBrowser, oauthFlowDevice:
+	default:
+		return fmt.Errorf(
+			"invalid OAuth flow %q, expected %s or %s",
+			options.Flow, oauthFlowBrowser, oauthFlowDevice,
+		)
+	}
+	if options.RedirectURI != "" {
+		if err := transport.ValidateRedirectURI(options.RedirectURI); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serverAuth authorizes mcphost with a remote server. The client
+// registration and tokens are cached in $HOME/.mcphost/tokens, so the user
+// only has to authorize once.
+type serverAuth struct {
+	serverName string
+	serverURL  string
+	configured bool
+	options    OAuthOptions
+	store      *fileTokenStore
+
+	// unauthorized is set when the server answered with 401 Unauthorized
+	mu           sync.Mutex
+	unauthorized bool
+}
+
+func newServerAuth(serverName, serverURL string, options *OAuthOptions) *serverAuth {
+	auth := &serverAuth{
+		serverName: serverName,
+		serverURL:  serverURL,
+		store:      &fileTokenStore{serverName: serverName, serverURL: serverURL},
+	}
+	if options != nil {
+		auth.configured = true
+		auth.options = *options
+	}
+	if auth.options.RedirectURI == "" {
+		auth.options.RedirectURI = defaultRedirectURI
+	}
+	return auth
+}
+
+// transportConfig returns the OAuth config for the transport, or nil for
+// servers that aren't configured for OAuth, haven't asked for authorization
+// and have no cached token
+func (a *serverAuth) transportConfig() *transport.OAuthConfig {
+	a.mu.Lock()
+	unauthorized := a.unauthorized
+	a.mu.Unlock()
+
+	if !a.configured && !unauthorized {
+		if cached, _ := a.store.load(); cached.Token == nil {
+			return nil
+		}
+	}
+	config := a.config()
+	return &config
+}
+
+func (a *serverAuth) config() transport.OAuthConfig {
+	cached, _ := a.store.load()
+	config := transport.OAuthConfig{
+		ClientID:              a.options.ClientID,
+		ClientSecret:          a.options.ClientSecret,
+		RedirectURI:           a.options.RedirectURI,
+		Scopes:                a.options.Scopes,
+		TokenStore:            a.store,
+		AuthServerMetadataURL: a.options.AuthServerMetadataURL,
+		PKCEEnabled:           true,
+	}
+	if config.ClientID == "" {
+		config.ClientID = cached.ClientID
+		config.ClientSecret = cached.ClientSecret
+	}
+	return config
+}
+
+// httpClient returns a client that notices when the server answers with
+// 401 Unauthorized
+func (a *serverAuth) httpClient(base http.RoundTripper) *http.Client {
+	return &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := base.RoundTrip(req)
+		if err == nil && resp.StatusCode == http.StatusUnauthorized {
+			a.mu.Lock()
+			a.unauthorized = true
+			a.mu.Unlock()
+		}
+		return resp, err
+	})}
+}
+
+// required reports whether err means that the server needs authorization
+func (a *serverAuth) required(err error) bool {
+	if mcpclient.IsOAuthAuthorizationRequiredError(err) {
+		return true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.unauthorized
+}
+
+// authorize runs the authorization flow and caches the token
+func (a *serverAuth) authorize(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, authorizeTimeout)
+	defer cancel()
+
+	config := a.config()
+	handler := transport.NewOAuthHandler(config)
+	if parsed, err := url.Parse(a.serverURL); err == nil {
+		handler.SetBaseURL(fmt.Sprintf("%s://%s", parsed.Scheme, parsed.Host))
+	}
+
+	if config.ClientID == "" {
+		if err := handler.RegisterClient(ctx, "mcphost"); err != nil {
+			return fmt.Errorf("error registering client: %w", err)
+		}
+		if err := a.store.saveClient(handler.GetClientID(), handler.GetClientSecret()); err != nil {
+			log.Warn("Failed to cache client registration", "server", a.serverName, "error", err)
+		}
+	}
+
+	var err error
+	withTerminal(func() {
+		if a.options.Flow == oauthFlowDevice {
+			err = a.deviceFlow(ctx, handler)
+		} else {
+			err = a.browserFlow(ctx, handler)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.unauthorized = false
+	a.mu.Unlock()
+	log.Info("Server authorized", "server", a.serverName)
+	return nil
+}
+
+// browserFlow has the user log in in the browser and receives the
+// authorization code on the redirect URI
+func (a *serverAuth) browserFlow(ctx context.Context, handler *transport.OAuthHandler) error {
+	redirect, err := url.Parse(a.options.RedirectURI)
+	if err != nil {
+		return fmt.Errorf("invalid redirect URI: %w", err)
+	}
+	listener, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", redirect.Host, err)
+	}
+
+	type callback struct {
+		code, state string
+		err         error
+	}
+	callbacks := make(chan callback, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(redirect.Path, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		result := callback{code: query.Get("code"), state: query.Get("state")}
+		if oauthErr := query.Get("error"); oauthErr != "" {
+			result.err = fmt.Errorf("authorization denied: %s %s", oauthErr, query.Get("error_description"))
+			fmt.Fprintln(w, "Authorization failed, you can close this window.")
+		} else {
+			fmt.Fprintln(w, "Authorization complete, you can close this window.")
+		}
+		select {
+		case callbacks <- result:
+		default:
+		}
+	})
+	server := &http.Server{Handler: mux}
+	go func() { _ = server.Serve(listener) }()
+	defer server.Close()
+
+	codeVerifier, err := transport.GenerateCodeVerifier()
+	if err != nil {
+		return err
+	}
+	state, err := transport.GenerateState()
+	if err != nil {
+		return err
+	}
+	authURL, err := handler.GetAuthorizationURL(ctx, state, transport.GenerateCodeChallenge(codeVerifier))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(
+		"\n%s\n%s\n\n",
+		promptStyle.Render(fmt.Sprintf("Server %s needs authorization. Open this URL to log in:", a.serverName)),
+		authURL,
+	)
+	if err := openBrowser(authURL); err != nil {
+		log.Debug("Failed to open browser", "error", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("no authorization received: %w", ctx.Err())
+	case result := <-callbacks:
+		if result.err != nil {
+			return result.err
+		}
+		return handler.ProcessAuthorizationResponse(ctx, result.code, result.state, codeVerifier)
+	}
+}
+
+// deviceFlow has the user enter a code on another device, and polls the
+// authorization server until they did (RFC 8628)
+func (a *serverAuth) deviceFlow(ctx context.Context, handler *transport.OAuthHandler) error {
+	endpoint, err := deviceAuthorizationEndpoint(ctx, handler)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{"client_id": {handler.GetClientID()}}
+	if len(a.options.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.options.Scopes, " "))
+	}
+	var device struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                int    `json:"interval"`
+	}
+	if err := postForm(ctx, endpoint, form, &device); err != nil {
+		return fmt.Errorf("error requesting device code: %w", err)
+	}
+
+	fmt.Printf(
+		"\n%s\n%s\n\n",
+		promptStyle.Render(fmt.Sprintf(
+			"Server %s needs authorization. Open this URL and enter the code %s:",
+			a.serverName, device.UserCode,
+		)),
+		device.VerificationURI,
+	)
+	if device.VerificationURIComplete != "" {
+		if err := openBrowser(device.VerificationURIComplete); err != nil {
+			log.Debug("Failed to open browser", "error", err)
+		}
+	}
+
+	metadata, err := handler.GetServerMetadata(ctx)
+	if err != nil {
+		return err
+	}
+	interval := time.Duration(device.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	if device.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(device.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+
+	form = url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": {device.DeviceCode},
+		"client_id":   {handler.GetClientID()},
+	}
+	if secret := handler.GetClientSecret(); secret != "" {
+		form.Set("client_secret", secret)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("no authorization received: %w", ctx.Err())
+		case <-time.After(interval):
+		}
+
+		var token transport.Token
+		err := postForm(ctx, metadata.TokenEndpoint, form, &token)
+		var oauthErr transport.OAuthError
+		if errors.As(err, &oauthErr) {
+			switch oauthErr.ErrorCode {
+			case "authorization_pending":
+				continue
+			case "slow_down":
+				interval += 5 * time.Second
+				continue
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("error requesting token: %w", err)
+		}
+
+		if token.ExpiresIn > 0 {
+			token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+		}
+		return a.store.SaveToken(ctx, &token)
+	}
+}
+
+// deviceAuthorizationEndpoint looks up the device authorization endpoint
+// in the metadata of the authorization server
+func deviceAuthorizationEndpoint(ctx context.Context, handler *transport.OAuthHandler) (string, error) {
+	metadata, err := handler.GetServerMetadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	issuer := strings.TrimSuffix(metadata.Issuer, "/")
+	for _, path := range []string{"/.well-known/oauth-authorization-server", "/.well-known/openid-configuration"} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+path, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			continue
+		}
+		var deviceMetadata struct {
+			DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&deviceMetadata)
+		resp.Body.Close()
+		if err == nil && resp.StatusCode == http.StatusOK && deviceMetadata.DeviceAuthorizationEndpoint != "" {
+			return deviceMetadata.DeviceAuthorizationEndpoint, nil
+		}
+	}
+	return "", errors.New("the authorization server does not support the device flow")
+}
+
+// postForm posts a form to an OAuth endpoint and decodes the JSON answer.
+// OAuth errors are returned as transport.OAuthError.
+func postForm(ctx context.Context, endpoint string, form url.Values, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr transport.OAuthError
+		if err := json.NewDecoder(resp.Body).Decode(&oauthErr); err == nil && oauthErr.ErrorCode != "" {
+			return oauthErr
+		}
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// openBrowser opens a URL in the browser of the user. Tests replace it to
+// act as the browser.
+var openBrowser = func(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// cachedAuth is what the token cache keeps for a server
+type cachedAuth struct {
+	URL          string           `json:"url"`
+	ClientID     string           `json:"client_id,omitempty"`
+	ClientSecret string           `json:"client_secret,omitempty"`
+	Token        *transport.Token `json:"token,omitempty"`
+}
+
+// fileTokenStore caches the client registration and token of a server in
+// $HOME/.mcphost/tokens/<server>.json. The cache is dropped when the URL of
+// the server changes.
+type fileTokenStore struct {
+	serverName string
+	serverURL  string
+
+	mu sync.Mutex
+}
+
+func (s *fileTokenStore) path() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".mcphost", "tokens", s.serverName+".json"), nil
+}
+
+func (s *fileTokenStore) load() (cachedAuth, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+func (s *fileTokenStore) read() (cachedAuth, error) {
+	path, err := s.path()
+	if err != nil {
+		return cachedAuth{}, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cachedAuth{URL: s.serverURL}, nil
+	}
+	if err != nil {
+		return cachedAuth{}, err
+	}
+
+	var cached cachedAuth
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return cachedAuth{}, fmt.Errorf("error reading token cache: %w", err)
+	}
+	if cached.URL != s.serverURL {
+		return cachedAuth{URL: s.serverURL}, nil
+	}
+	return cached, nil
+}
+
+func (s *fileTokenStore) update(fn func(cached *cachedAuth)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cached, err := s.read()
+	if err != nil {
+		cached = cachedAuth{URL: s.serverURL}
+	}
+	fn(&cached)
+
+	path, err := s.path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cached, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+func (s *fileTokenStore) saveClient(clientID, clientSecret string) error {
+	return s.update(func(cached *cachedAuth) {
+		cached.ClientID = clientID
+		cached.ClientSecret = clientSecret
+	})
+}
+
+// GetToken implements transport.TokenStore
+func (s *fileTokenStore) GetToken(ctx context.Context) (*transport.Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cached, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	if cached.Token == nil {
+		return nil, transport.ErrNoToken
+	}
+	return cached.Token, nil
+}
+
+// SaveToken implements transport.TokenStore
+func (s *fileTokenStore) SaveToken(ctx context.Context, token *transport.Token) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.update(func(cached *cachedAuth) {
+		cached.Token = token
+	})
+}
@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// authServer stands in for a remote MCP server and its authorization
+// server. The MCP endpoint at /mcp answers with 401 Unauthorized until it
+// is sent the token the authorization server hands out.
+type authServer struct {
+	*httptest.Server
+	t *testing.T
+
+	mu         sync.Mutex
+	challenges map[string]string
+	pending    int
+	tokens     int
+}
+
+const (
+	testClientID    = "client-1"
+	testAccessToken = "token-1"
+)
+
+func newAuthServer(t *testing.T, pending int) *authServer {
+	s := &authServer{t: t, challenges: make(map[string]string), pending: pending}
+
+	mcpServer := server.NewMCPServer("stand-in", "1.0.0", server.WithToolCapabilities(false))
+	mcpServer.AddTool(mcp.NewTool("echo"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+	mcpHandler := server.NewStreamableHTTPServer(mcpServer)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/oauth-authorization-server", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                        s.URL,
+			"authorization_endpoint":        s.URL + "/authorize",
+			"token_endpoint":                s.URL + "/token",
+			"registration_endpoint":         s.URL + "/register",
+			"device_authorization_endpoint": s.URL + "/device",
+		})
+	})
+	mux.HandleFunc("POST /register", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, map[string]string{"client_id": testClientID})
+	})
+	mux.HandleFunc("GET /authorize", s.authorize)
+	mux.HandleFunc("POST /device", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"device_code":      "device-1",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": s.URL + "/activate",
+			"expires_in":       60,
+			"interval":         1,
+		})
+	})
+	mux.HandleFunc("POST /token", s.token)
+	mux.HandleFunc("/mcp", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testAccessToken {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mcpHandler.ServeHTTP(w, r)
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// authorize logs the user in right away and redirects back with a code
+func (s *authServer) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != testClientID || query.Get("code_challenge_method") != "S256" {
+		s.t.Errorf("authorization request = %s", r.URL.RawQuery)
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.challenges["code-1"] = query.Get("code_challenge")
+	s.mu.Unlock()
+
+	redirect := fmt.Sprintf("%s?code=code-1&state=%s", query.Get("redirect_uri"), query.Get("state"))
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+func (s *authServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		challenge, ok := s.challenges[r.PostForm.Get("code")]
+		if !ok || transport.GenerateCodeChallenge(r.PostForm.Get("code_verifier")) != challenge {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+	case "urn:ietf:params:oauth:grant-type:device_code":
+		if r.PostForm.Get("device_code") != "device-1" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		if s.pending > 0 {
+			s.pending--
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "authorization_pending"})
+			return
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	s.tokens++
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": testAccessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// useBrowser makes openBrowser call fn instead of opening a browser
+func useBrowser(t *testing.T, fn func(url string) error) {
+	original := openBrowser
+	openBrowser = fn
+	t.Cleanup(func() { openBrowser = original })
+}
+
+// followBrowser acts as a browser in which the user logs in right away
+func followBrowser(url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// freeRedirectURI returns a redirect URI on a port nothing listens on
+func freeRedirectURI(t *testing.T) string {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return fmt.Sprintf("http://localhost:%d/oauth/callback", port)
+}
+
+// checkTokenStore checks that the token cache of a server holds the client
+// registration and token, and that only the user can read it
+func checkTokenStore(t *testing.T, home, serverName, serverURL string) {
+	t.Helper()
+	dir := filepath.Join(home, ".mcphost", "tokens")
+	path := filepath.Join(dir, serverName+".json")
+
+	if info, err := os.Stat(dir); err != nil {
+		t.Fatal(err)
+	} else if perm := info.Mode().Perm(); perm != 0o700 {
+		t.Errorf("token directory permissions = %o, want 700", perm)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("token file permissions = %o, want 600", perm)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cached cachedAuth
+	if err := json.Unmarshal(data, &cached); err != nil {
+		t.Fatal(err)
+	}
+	if cached.URL != serverURL || cached.ClientID != testClientID {
+		t.Errorf("cached URL %q and client %q, want %q and %q", cached.URL, cached.ClientID, serverURL, testClientID)
+	}
+	if cached.Token == nil || cached.Token.AccessToken != testAccessToken || cached.Token.ExpiresAt.IsZero() {
+		t.Errorf("cached token = %+v, want %s with an expiry", cached.Token, testAccessToken)
+	}
+}
+
+func TestServerAuthFlows(t *testing.T) {
+	tests := []struct {
+		name    string
+		flow    string
+		pending int
+	}{
+		{name: "browser", flow: oauthFlowBrowser},
+		{name: "device", flow: oauthFlowDevice, pending: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			authServer := newAuthServer(t, test.pending)
+			useBrowser(t, followBrowser)
+
+			serverURL := authServer.URL + "/mcp"
+			auth := newServerAuth("remote", serverURL, &OAuthOptions{
+				Flow:        test.flow,
+				RedirectURI: freeRedirectURI(t),
+			})
+			if err := auth.authorize(context.Background()); err != nil {
+				t.Fatalf("authorize() error = %v", err)
+			}
+
+			checkTokenStore(t, home, "remote", serverURL)
+			if authServer.tokens != 1 {
+				t.Errorf("%d tokens issued, want 1", authServer.tokens)
+			}
+		})
+	}
+}
+
+func TestConnectMCPServerAuthorizes(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured bool
+	}{
+		// The server is set up for OAuth in the config
+		{name: "configured", configured: true},
+		// The server only asks for authorization with 401 Unauthorized
+		{name: "unauthorized", configured: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			authServer := newAuthServer(t, 0)
+			useBrowser(t, followBrowser)
+
+			serverURL := authServer.URL + "/mcp"
+			config := ServerConfigWrapper{Config: StreamableHTTPServerConfig{
+				Transport: transportHTTP,
+				Url:       serverURL,
+			}}
+			sampler := newSamplingHandler(&MCPConfig{}, false)
+			roots := &rootsHandler{}
+			connect := func() error {
+				var options *OAuthOptions
+				if test.configured {
+					options = &OAuthOptions{}
+				}
+				auth := newServerAuth("remote", serverURL, options)
+				auth.options.RedirectURI = freeRedirectURI(t)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				client, err := connectMCPServer(ctx, "remote", config, auth, sampler, roots)
+				if err != nil {
+					return err
+				}
+				defer client.Close()
+
+				result, err := client.ListTools(ctx, mcp.ListToolsRequest{})
+				if err != nil {
+					return err
+				}
+				if len(result.Tools) != 1 || result.Tools[0].Name != "echo" {
+					return fmt.Errorf("tools = %+v, want echo", result.Tools)
+				}
+				return nil
+			}
+
+			if err := connect(); err != nil {
+				t.Fatalf("connecting after authorizing: %v", err)
+			}
+			checkTokenStore(t, home, "remote", serverURL)
+
+			// The cached token is used from then on
+			useBrowser(t, func(url string) error {
+				t.Errorf("browser opened again for %s", url)
+				return errors.New("no browser")
+			})
+			if err := connect(); err != nil {
+				t.Fatalf("connecting with the cached token: %v", err)
+			}
+			if authServer.tokens != 1 {
+				t.Errorf("%d tokens issued, want 1", authServer.tokens)
+			}
+		})
+	}
+}
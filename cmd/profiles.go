@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/spf13/pflag"
+
+	"github.com/mark3labs/mcphost/pkg/llm"
+)
+
+// Profile bundles the model, system prompt, servers and generation
+// parameters of one way of working, such as a local model with the
+// filesystem server. Settings the profile leaves out keep the value of the
+// config.
+type Profile struct {
+	Model            string `json:"model,omitempty"`
+	SystemPrompt     string `json:"systemPrompt,omitempty"`
+	SystemPromptFile string `json:"systemPromptFile,omitempty"`
+
+	// Servers are the names of the servers the profile uses. All servers
+	// of the config are used when it is empty.
+	Servers []string `json:"servers,omitempty"`
+
+	Generation *llm.GenerationParams `json:"generation,omitempty"`
+}
+
+// applyProfile puts the settings of a profile over the config and drops
+// the servers the profile doesn't use
+func (c *MCPConfig) applyProfile(name string) error {
+	profile, ok := c.Profiles[name]
+	if !ok || profile == nil {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	for _, serverName := range profile.Servers {
+		if _, ok := c.MCPServers[serverName]; !ok {
+			return fmt.Errorf("profile %s uses server %s, which is not configured", name, serverName)
+		}
+	}
+
+	source := "profile " + name
+	c.merge(&MCPConfig{
+		Model:            profile.Model,
+		SystemPrompt:     profile.SystemPrompt,
+		SystemPromptFile: profile.SystemPromptFile,
+		Generation:       profile.Generation,
+	}, source)
+
+	if len(profile.Servers) > 0 {
+		for serverName := range c.MCPServers {
+			if !slices.Contains(profile.Servers, serverName) {
+				delete(c.MCPServers, serverName)
+				c.sources["mcpServers."+serverName] = "disabled by " + source
+			}
+		}
+	}
+
+	c.activeProfile = name
+	return nil
+}
+
+// switchProfile loads the config again with another profile and makes
+// mcphost use it. Servers the profile doesn't use any more are stopped, new
+// ones are started and servers whose config changed are restarted. It
+// returns the provider for the model of the profile.
+func switchProfile(
+	ctx context.Context,
+	flags *pflag.FlagSet,
+	name string,
+	config *MCPConfig,
+	mcpClients map[string]mcpclient.MCPClient,
+	tools *toolSet,
+	sampler *samplingHandler,
+	roots *rootsHandler,
+) (llm.Provider, error) {
+	settingsMu.Lock()
+	newConfig, provider, err := useProfile(ctx, flags, name, config)
+	settingsMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for serverName, client := range mcpClients {
+		server, ok := newConfig.MCPServers[serverName]
+		if ok && sameServer(server, config.MCPServers[serverName]) {
+			continue
+		}
+		if err := client.Close(); err != nil {
+			log.Error("Failed to close server", "name", serverName, "error", err)
+		} else {
+			log.Info("Server closed", "name", serverName)
+		}
+		delete(mcpClients, serverName)
+		tools.remove(serverName)
+	}
+
+	added := make(map[string]ServerConfigWrapper)
+	for serverName, server := range newConfig.MCPServers {
+		if _, ok := mcpClients[serverName]; !ok {
+			added[serverName] = server
+		}
+	}
+
+	// The tool set, the approver and the sampling handler keep the config,
+	// so it is updated in place
+	settingsMu.Lock()
+	*config = *newConfig
+	settingsMu.Unlock()
+
+	started := createMCPClients(config, added, sampler, roots)
+	for serverName, client := range started {
+		mcpClients[serverName] = client
+	}
+	watchNotifications(started, tools)
+	tools.loadAll(ctx, started)
+
+	log.Info("Profile switched", "profile", name, "model", modelFlag)
+	return provider, nil
+}
+
+// useProfile loads the config with a profile and uses its settings. The
+// settings of the current config are kept unless the model of the profile
+// can be used. The caller holds settingsMu.
+func useProfile(
+	ctx context.Context,
+	flags *pflag.FlagSet,
+	name string,
+	config *MCPConfig,
+) (*MCPConfig, llm.Provider, error) {
+	newConfig, err := loadMCPConfig(flags, name)
+	if err != nil {
+		_ = useSettings(config)
+		return nil, nil, err
+	}
+	if err := useSettings(newConfig); err != nil {
+		_ = useSettings(config)
+		return nil, nil, err
+	}
+	systemPrompt, err := newConfig.systemPrompt()
+	if err != nil {
+		_ = useSettings(config)
+		return nil, nil, fmt.Errorf("error loading system prompt: %w", err)
+	}
+	provider, err := createProvider(ctx, modelFlag, systemPrompt)
+	if err != nil {
+		_ = useSettings(config)
+		return nil, nil, fmt.Errorf("error creating provider: %w", err)
+	}
+	useContextBudget(provider, systemPrompt)
+	return newConfig, provider, nil
+}
+
+// sameServer reports whether two server configs are the same
+func sameServer(a, b ServerConfigWrapper) bool {
+	if a.Config == nil || b.Config == nil {
+		return false
+	}
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(dataA) == string(dataB)
+}
+
+func handleProfilesCommand(config *MCPConfig) {
+	if err := updateRenderer(); err != nil {
+		fmt.Printf(
+			"\n%s\n",
+			errorStyle.Render(fmt.Sprintf("Error updating renderer: %v", err)),
+		)
+		return
+	}
+
+	var markdown strings.Builder
+	if len(config.Profiles) == 0 {
+		markdown.WriteString("No profiles configured.\n")
+	} else {
+		names := make([]string, 0, len(config.Profiles))
+		for name := range config.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		markdown.WriteString("# Profiles\n\n")
+		for _, name := range names {
+			profile := config.Profiles[name]
+			line := fmt.Sprintf("- **%s**", name)
+			if name == config.activeProfile {
+				line += " (active)"
+			}
+			if profile != nil && profile.Model != "" {
+				line += fmt.Sprintf(": `%s`", profile.Model)
+			}
+			if profile != nil && len(profile.Servers) > 0 {
+				line += fmt.Sprintf(" with %s", strings.Join(profile.Servers, ", "))
+			}
+			markdown.WriteString(line + "\n")
+		}
+		markdown.WriteString("\nSwitch with `/profile name`.\n")
+	}
+
+	rendered, err := renderer.Render(markdown.String())
+	if err != nil {
+		fmt.Printf(
+			"\n%s\n",
+			errorStyle.Render(fmt.Sprintf("Error rendering profiles: %v", err)),
+		)
+		return
+	}
+	fmt.Print(rendered)
+}
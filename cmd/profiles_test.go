@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcphost/pkg/llm"
+)
+
+func TestApplyProfile(t *testing.T) {
+	temperature := 0.2
+
+	tests := []struct {
+		name    string
+		profile string
+
+		wantErr     string
+		wantServers string
+		wantModel   string
+		wantSources map[string]string
+	}{
+		{
+			name:        "settings",
+			profile:     "local",
+			wantServers: "fs git web",
+			wantModel:   "ollama:qwen2.5:3b",
+			wantSources: map[string]string{
+				"model":                  "profile local",
+				"systemPrompt":           "profile local",
+				"generation.temperature": "profile local",
+				"generation.maxTokens":   "config",
+			},
+		},
+		{
+			name:        "servers",
+			profile:     "review",
+			wantServers: "fs git",
+			wantModel:   "anthropic:claude-3-5-sonnet-latest",
+			wantSources: map[string]string{
+				"model":          "config",
+				"mcpServers.fs":  "config",
+				"mcpServers.web": "disabled by profile review",
+			},
+		},
+		{name: "unknown profile", profile: "remote", wantErr: `unknown profile "remote"`},
+		{
+			name:    "unknown server",
+			profile: "broken",
+			wantErr: "profile broken uses server db, which is not configured",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &MCPConfig{
+				MCPServers: make(map[string]ServerConfigWrapper),
+				sources:    make(map[string]string),
+			}
+			config.merge(&MCPConfig{
+				Model:      "anthropic:claude-3-5-sonnet-latest",
+				Generation: &llm.GenerationParams{MaxTokens: 1024},
+				MCPServers: map[string]ServerConfigWrapper{
+					"fs":  {Config: STDIOServerConfig{Command: "fs-server"}},
+					"git": {Config: STDIOServerConfig{Command: "git-server"}},
+					"web": {Config: SSEServerConfig{Url: "https://example.com/sse"}},
+				},
+				Profiles: map[string]*Profile{
+					"local": {
+						Model:        "ollama:qwen2.5:3b",
+						SystemPrompt: "Answer briefly.",
+						Generation:   &llm.GenerationParams{Temperature: &temperature},
+					},
+					"review": {Servers: []string{"fs", "git"}},
+					"broken": {Servers: []string{"fs", "db"}},
+				},
+			}, "config")
+
+			err := config.applyProfile(test.profile)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("applyProfile(%q) error = %v, want %q", test.profile, err, test.wantErr)
+				}
+				if config.activeProfile != "" {
+					t.Errorf("active profile = %q, want none", config.activeProfile)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyProfile(%q) error = %v", test.profile, err)
+			}
+
+			if config.activeProfile != test.profile {
+				t.Errorf("active profile = %q, want %q", config.activeProfile, test.profile)
+			}
+			if got := serverNames(config); got != test.wantServers {
+				t.Errorf("servers = %q, want %q", got, test.wantServers)
+			}
+			if config.Model != test.wantModel {
+				t.Errorf("model = %q, want %q", config.Model, test.wantModel)
+			}
+			for key, want := range test.wantSources {
+				if config.sources[key] != want {
+					t.Errorf("source of %s = %q, want %q", key, config.sources[key], want)
+				}
+			}
+		})
+	}
+}
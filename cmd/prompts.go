@@ -0,0 +1,331 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/list"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/mark3labs/mcphost/pkg/history"
+)
+
+// parsePromptCommand splits a /server:prompt command into the server, the
+// prompt name and its arguments. ok is false if the command doesn't name a
+// prompt of a connected server.
+func parsePromptCommand(
+	command string,
+	mcpClients map[string]mcpclient.MCPClient,
+) (serverName, promptName, rest string, ok bool) {
+	command = strings.TrimSpace(command)
+	if !strings.HasPrefix(command, "/") {
+		return "", "", "", false
+	}
+
+	name, rest, _ := strings.Cut(command[1:], " ")
+	serverName, promptName, found := strings.Cut(name, ":")
+	if !found || promptName == "" {
+		return "", "", "", false
+	}
+	if _, exists := mcpClients[serverName]; !exists {
+		return "", "", "", false
+	}
+	return serverName, promptName, rest, true
+}
+
+// parsePromptArgs parses arguments given as name=value. Values containing
+// spaces can be put in double quotes.
+func parsePromptArgs(s string) (map[string]string, error) {
+	args := make(map[string]string)
+
+	var fields []string
+	var field strings.Builder
+	inQuotes := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == ' ' && !inQuotes:
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(r)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in arguments")
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+
+	for _, f := range fields {
+		name, value, found := strings.Cut(f, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid argument %q, expected name=value", f)
+		}
+		args[name] = value
+	}
+	return args, nil
+}
+
+// runServerPrompt gets the messages of a prompt of an MCP server and shows
+// them. rest holds the arguments given with the command.
+func runServerPrompt(
+	ctx context.Context,
+	mcpClient mcpclient.MCPClient,
+	promptName string,
+	rest string,
+) ([]history.HistoryMessage, error) {
+	args, err := parsePromptArgs(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := getPrompt(ctx, mcpClient, promptName, args)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, message := range messages {
+		prefix := "You: "
+		if message.Role == string(mcp.RoleAssistant) {
+			prefix = "Assistant: "
+		}
+		fmt.Printf("\n%s\n", promptStyle.Render(prefix+message.GetContent()))
+	}
+	return messages, nil
+}
+
+// getPrompt fetches a prompt of an MCP server and converts its messages to
+// history messages. Declared arguments that weren't given are asked for.
+func getPrompt(
+	ctx context.Context,
+	mcpClient mcpclient.MCPClient,
+	promptName string,
+	args map[string]string,
+) ([]history.HistoryMessage, error) {
+	listCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	promptsResult, err := mcpClient.ListPrompts(listCtx, mcp.ListPromptsRequest{})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("error listing prompts: %w", err)
+	}
+
+	var prompt *mcp.Prompt
+	for i := range promptsResult.Prompts {
+		if promptsResult.Prompts[i].Name == promptName {
+			prompt = &promptsResult.Prompts[i]
+			break
+		}
+	}
+	if prompt == nil {
+		return nil, fmt.Errorf("prompt not found: %s", promptName)
+	}
+
+	declared := make(map[string]bool)
+	for _, arg := range prompt.Arguments {
+		declared[arg.Name] = true
+	}
+	for name := range args {
+		if !declared[name] {
+			return nil, fmt.Errorf("prompt %s has no argument %s", promptName, name)
+		}
+	}
+
+	if err := askPromptArgs(prompt, args); err != nil {
+		return nil, err
+	}
+
+	getCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req := mcp.GetPromptRequest{}
+	req.Params.Name = promptName
+	req.Params.Arguments = args
+	result, err := mcpClient.GetPrompt(getCtx, req)
+	if err != nil {
+		return nil, fmt.Errorf("error getting prompt %s: %w", promptName, err)
+	}
+
+	messages := make([]history.HistoryMessage, 0, len(result.Messages))
+	for _, message := range result.Messages {
+		messages = append(messages, history.HistoryMessage{
+			Role: string(message.Role),
+			Content: []history.ContentBlock{{
+				Type: "text",
+				Text: promptContentText(message.Content),
+			}},
+		})
+	}
+	return messages, nil
+}
+
+// askPromptArgs shows a form for the declared arguments of a prompt that
+// are missing from args
+func askPromptArgs(prompt *mcp.Prompt, args map[string]string) error {
+	values := make(map[string]*string)
+	var fields []huh.Field
+	for _, arg := range prompt.Arguments {
+		if _, ok := args[arg.Name]; ok {
+			continue
+		}
+
+		value := new(string)
+		values[arg.Name] = value
+
+		title := arg.Name
+		if !arg.Required {
+			title += " (optional)"
+		}
+		input := huh.NewInput().
+			Title(title).
+			Description(arg.Description).
+			Value(value)
+		if arg.Required {
+			name := arg.Name
+			input.Validate(func(s string) error {
+				if strings.TrimSpace(s) == "" {
+					return fmt.Errorf("%s is required", name)
+				}
+				return nil
+			})
+		}
+		fields = append(fields, input)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	err := huh.NewForm(huh.NewGroup(fields...).
+		Title(prompt.Name).
+		Description(prompt.Description),
+	).WithWidth(getTerminalWidth()).
+		WithTheme(huh.ThemeCharm()).
+		Run()
+	if err != nil {
+		return err
+	}
+
+	for name, value := range values {
+		if *value != "" {
+			args[name] = *value
+		}
+	}
+	return nil
+}
+
+// promptContentText returns the content of a prompt message as text.
+// Content that can't be shown as text is replaced by a short note.
+func promptContentText(content mcp.Content) string {
+	switch c := content.(type) {
+	case mcp.TextContent:
+		return c.Text
+	case mcp.ImageContent:
+		return fmt.Sprintf("[image, type %s]", c.MIMEType)
+	case mcp.EmbeddedResource:
+		switch r := c.Resource.(type) {
+		case mcp.TextResourceContents:
+			return fmt.Sprintf("<resource uri=%q>\n%s\n</resource>", r.URI, r.Text)
+		case mcp.BlobResourceContents:
+			return fmt.Sprintf("[binary content of %s, type %s]", r.URI, r.MIMEType)
+		}
+	}
+	return fmt.Sprintf("%v", content)
+}
+
+func handlePromptsCommand(mcpClients map[string]mcpclient.MCPClient) {
+	width := getTerminalWidth()
+	contentWidth := width - 12
+
+	type serverPrompts struct {
+		prompts []mcp.Prompt
+		err     error
+	}
+	results := make(map[string]serverPrompts)
+
+	action := func() {
+		for serverName, mcpClient := range mcpClients {
+			ctx, cancel := context.WithTimeout(
+				context.Background(),
+				10*time.Second,
+			)
+			promptsResult, err := mcpClient.ListPrompts(ctx, mcp.ListPromptsRequest{})
+			cancel()
+			if err != nil {
+				results[serverName] = serverPrompts{err: err}
+				continue
+			}
+			results[serverName] = serverPrompts{prompts: promptsResult.Prompts}
+		}
+	}
+	_ = spinner.New().
+		Title("Fetching prompts from all servers...").
+		Action(action).
+		Run()
+
+	l := list.New().
+		EnumeratorStyle(lipgloss.NewStyle().Foreground(tokyoPurple).MarginRight(1))
+
+	descStyle := lipgloss.NewStyle().
+		Foreground(tokyoFg).
+		Width(contentWidth).
+		Align(lipgloss.Left)
+
+	for serverName, result := range results {
+		if result.err != nil {
+			fmt.Printf(
+				"\n%s\n",
+				errorStyle.Render(
+					fmt.Sprintf(
+						"Error fetching prompts from %s: %v",
+						serverName,
+						result.err,
+					),
+				),
+			)
+			continue
+		}
+
+		serverList := list.New().
+			EnumeratorStyle(lipgloss.NewStyle().Foreground(tokyoCyan).MarginRight(1))
+
+		if len(result.prompts) == 0 {
+			serverList.Item("No prompts available")
+		}
+		for _, prompt := range result.prompts {
+			details := list.New().
+				EnumeratorStyle(lipgloss.NewStyle().Foreground(tokyoGreen).MarginRight(1))
+			if prompt.Description != "" {
+				details.Item(descStyle.Render(prompt.Description))
+			}
+			for _, arg := range prompt.Arguments {
+				argText := arg.Name
+				if arg.Required {
+					argText += " (required)"
+				}
+				if arg.Description != "" {
+					argText += ": " + arg.Description
+				}
+				details.Item(descStyle.Render(argText))
+			}
+			serverList.Item(toolNameStyle.Render("/" + serverName + ":" + prompt.Name)).Item(details)
+		}
+
+		l.Item(serverName).Item(serverList)
+	}
+
+	containerStyle := lipgloss.NewStyle().
+		Margin(2).
+		Width(width)
+
+	fmt.Print("\n" + containerStyle.Render(l.String()) + "\n")
+}
@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/list"
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/mark3labs/mcphost/pkg/agent"
+	"github.com/mark3labs/mcphost/pkg/history"
+	"github.com/mark3labs/mcphost/pkg/llm"
+)
+
+// hostServerName namespaces the tools mcphost provides itself, next to the
+// tools of the MCP servers
+const hostServerName = "mcphost"
+
+// Tools exposed with --resource-tools
+const (
+	listResourcesTool = "list_resources"
+	readResourceTool  = "read_resource"
+)
+
+// resourceMention matches @server:uri in a prompt
+var resourceMention = regexp.MustCompile(`(?:^|\s)@([^\s:@]+):(\S+)`)
+
+// readResource reads a resource and returns its contents as text. Binary
+// contents are replaced by a short note.
+func readResource(
+	ctx context.Context,
+	mcpClient mcpclient.MCPClient,
+	uri string,
+) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req := mcp.ReadResourceRequest{}
+	req.Params.URI = uri
+	result, err := mcpClient.ReadResource(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	var parts []string
+	for _, content := range result.Contents {
+		switch c := content.(type) {
+		case mcp.TextResourceContents:
+			parts = append(parts, c.Text)
+		case mcp.BlobResourceContents:
+			parts = append(parts, fmt.Sprintf("[binary content of %s, type %s]", c.URI, c.MIMEType))
+		}
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// attachResources reads the resources mentioned in a prompt as @server:uri
+// and returns them as a message to add to the history before the prompt.
+// Mentions of unknown servers are left alone. It returns nil if nothing is
+// mentioned.
+func attachResources(
+	ctx context.Context,
+	mcpClients map[string]mcpclient.MCPClient,
+	prompt string,
+) (*history.HistoryMessage, error) {
+	var blocks []history.ContentBlock
+	seen := make(map[string]bool)
+
+	for _, match := range resourceMention.FindAllStringSubmatch(prompt, -1) {
+		serverName, uri := match[1], match[2]
+		mcpClient, ok := mcpClients[serverName]
+		if !ok || seen[serverName+":"+uri] {
+			continue
+		}
+		seen[serverName+":"+uri] = true
+
+		text, err := readResource(ctx, mcpClient, uri)
+		if err != nil {
+			return nil, fmt.Errorf("error reading resource %s from %s: %w", uri, serverName, err)
+		}
+
+		log.Info("📎 Attached resource", "server", serverName, "uri", uri)
+		blocks = append(blocks, history.ContentBlock{
+			Type: "text",
+			Text: fmt.Sprintf("<resource server=%q uri=%q>\n%s\n</resource>", serverName, uri, text),
+		})
+	}
+
+	if len(blocks) == 0 {
+		return nil, nil
+	}
+	return &history.HistoryMessage{
+		Role:    "user",
+		Content: blocks,
+	}, nil
+}
+
+// resourceTools returns the tools that let the model list and read the
+// resources of the MCP servers
+func resourceTools() []llm.Tool {
+	return []llm.Tool{
+		{
+			Name:        agent.ToolName(hostServerName, listResourcesTool),
+			Description: "List the resources the connected MCP servers provide. Use read_resource to read one.",
+			InputSchema: llm.Schema{
+				Type: "object",
+				Properties: map[string]interface{}{
+					"server": map[string]interface{}{
+						"type":        "string",
+						"description": "Only list the resources of this server",
+					},
+				},
+				Required: []string{},
+			},
+		},
+		{
+			Name:        agent.ToolName(hostServerName, readResourceTool),
+			Description: "Read a resource from an MCP server.",
+			InputSchema: llm.Schema{
+				Type: "object",
+				Properties: map[string]interface{}{
+					"server": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of the server providing the resource",
+					},
+					"uri": map[string]interface{}{
+						"type":        "string",
+						"description": "URI of the resource",
+					},
+				},
+				Required: []string{"server", "uri"},
+			},
+		},
+	}
+}
+
+// hasServer reports whether tool calls for serverName can be handled
+func hasServer(mcpClients map[string]mcpclient.MCPClient, serverName string) bool {
+	_, ok := mcpClients[serverName]
+	return ok || serverName == hostServerName
+}
+
+// callTool runs a tool of an MCP server, or one of mcphost's own tools
+func callTool(
+	ctx context.Context,
+	mcpClients map[string]mcpclient.MCPClient,
+	serverName, toolName string,
+	args map[string]interface{},
+) (*mcp.CallToolResult, error) {
+	if serverName == hostServerName {
+		return callHostTool(ctx, mcpClients, toolName, args)
+	}
+
+	mcpClient, ok := mcpClients[serverName]
+	if !ok {
+		return nil, fmt.Errorf("server not found: %s", serverName)
+	}
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = toolName
+	req.Params.Arguments = args
+	return mcpClient.CallTool(ctx, req)
+}
+
+func callHostTool(
+	ctx context.Context,
+	mcpClients map[string]mcpclient.MCPClient,
+	toolName string,
+	args map[string]interface{},
+) (*mcp.CallToolResult, error) {
+	server, _ := args["server"].(string)
+
+	switch toolName {
+	case listResourcesTool:
+		var names []string
+		for name := range mcpClients {
+			if server == "" || server == name {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		var text strings.Builder
+		for _, name := range names {
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			result, err := mcpClients[name].ListResources(ctx, mcp.ListResourcesRequest{})
+			cancel()
+			if err != nil {
+				log.Debug("Error listing resources", "server", name, "error", err)
+				continue
+			}
+			for _, resource := range result.Resources {
+				text.WriteString(fmt.Sprintf("server=%s uri=%s name=%s", name, resource.URI, resource.Name))
+				if resource.Description != "" {
+					text.WriteString(": " + resource.Description)
+				}
+				text.WriteString("\n")
+			}
+		}
+		if text.Len() == 0 {
+			return mcp.NewToolResultText("No resources available."), nil
+		}
+		return mcp.NewToolResultText(text.String()), nil
+
+	case readResourceTool:
+		uri, _ := args["uri"].(string)
+		mcpClient, ok := mcpClients[server]
+		if !ok {
+			return nil, fmt.Errorf("server not found: %s", server)
+		}
+		text, err := readResource(ctx, mcpClient, uri)
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(text), nil
+	}
+
+	return nil, fmt.Errorf("unknown tool: %s", toolName)
+}
+
+func handleResourcesCommand(mcpClients map[string]mcpclient.MCPClient) {
+	width := getTerminalWidth()
+	contentWidth := width - 12
+
+	type serverResources struct {
+		resources []mcp.Resource
+		templates []mcp.ResourceTemplate
+		err       error
+	}
+	results := make(map[string]serverResources)
+
+	action := func() {
+		for serverName, mcpClient := range mcpClients {
+			ctx, cancel := context.WithTimeout(
+				context.Background(),
+				10*time.Second,
+			)
+
+			resourcesResult, err := mcpClient.ListResources(ctx, mcp.ListResourcesRequest{})
+			if err != nil {
+				cancel()
+				results[serverName] = serverResources{err: err}
+				continue
+			}
+
+			// Templates are optional, a server without any may not
+			// implement the request at all
+			var templates []mcp.ResourceTemplate
+			templatesResult, err := mcpClient.ListResourceTemplates(ctx, mcp.ListResourceTemplatesRequest{})
+			if err == nil {
+				templates = templatesResult.ResourceTemplates
+			}
+			cancel()
+
+			results[serverName] = serverResources{
+				resources: resourcesResult.Resources,
+				templates: templates,
+			}
+		}
+	}
+	_ = spinner.New().
+		Title("Fetching resources from all servers...").
+		Action(action).
+		Run()
+
+	l := list.New().
+		EnumeratorStyle(lipgloss.NewStyle().Foreground(tokyoPurple).MarginRight(1))
+
+	descStyle := lipgloss.NewStyle().
+		Foreground(tokyoFg).
+		Width(contentWidth).
+		Align(lipgloss.Left)
+
+	for serverName, result := range results {
+		if result.err != nil {
+			fmt.Printf(
+				"\n%s\n",
+				errorStyle.Render(
+					fmt.Sprintf(
+						"Error fetching resources from %s: %v",
+						serverName,
+						result.err,
+					),
+				),
+			)
+			continue
+		}
+
+		serverList := list.New().
+			EnumeratorStyle(lipgloss.NewStyle().Foreground(tokyoCyan).MarginRight(1))
+
+		if len(result.resources) == 0 && len(result.templates) == 0 {
+			serverList.Item("No resources available")
+		}
+		for _, resource := range result.resources {
+			details := list.New().
+				EnumeratorStyle(lipgloss.NewStyle().Foreground(tokyoGreen).MarginRight(1)).
+				Item(descStyle.Render("@" + serverName + ":" + resource.URI))
+			if resource.Description != "" {
+				details.Item(descStyle.Render(resource.Description))
+			}
+			serverList.Item(toolNameStyle.Render(resource.Name)).Item(details)
+		}
+		for _, template := range result.templates {
+			details := list.New().
+				EnumeratorStyle(lipgloss.NewStyle().Foreground(tokyoGreen).MarginRight(1))
+			if template.URITemplate != nil && template.URITemplate.Template != nil {
+				details.Item(descStyle.Render("template: " + template.URITemplate.Raw()))
+			}
+			if template.Description != "" {
+				details.Item(descStyle.Render(template.Description))
+			}
+			serverList.Item(toolNameStyle.Render(template.Name)).Item(details)
+		}
+
+		l.Item(serverName).Item(serverList)
+	}
+
+	containerStyle := lipgloss.NewStyle().
+		Margin(2).
+		Width(width)
+
+	fmt.Print("\n" + containerStyle.Render(l.String()) + "\n")
+}
@@ -6,20 +6,21 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/glamour/styles"
 	"github.com/charmbracelet/huh"
-	"github.com/charmbracelet/huh/spinner"
 	"github.com/charmbracelet/log"
 
 	"github.com/charmbracelet/glamour"
 	mcpclient "github.com/mark3labs/mcp-go/client"
-	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"golang.org/x/term"
 
+	"github.com/mark3labs/mcphost/pkg/agent"
 	"github.com/mark3labs/mcphost/pkg/history"
 	"github.com/mark3labs/mcphost/pkg/llm"
 	"github.com/mark3labs/mcphost/pkg/llm/anthropic"
@@ -33,14 +34,51 @@ var (
 	configFile       string
 	systemPromptFile string
 	messageWindow    int
+	contextLength    int
+	maxResultTokens  int
+	toolConcurrency  int
+	toolTimeout      int
+	maxSteps         int
+	maxRepeatedCalls int
 	modelFlag        string // New flag for model selection
 	openaiBaseURL    string // Base URL for OpenAI API
 	anthropicBaseURL string // Base URL for Anthropic API
 	openaiAPIKey     string
 	anthropicAPIKey  string
 	googleAPIKey     string
+	temperatureFlag  float64
+	topPFlag         float64
+	maxTokensFlag    int
+
+	// ollamaBaseURL and generationParams only come from the config, or the
+	// generation flags
+	ollamaBaseURL    string
+	generationParams llm.GenerationParams
 )
 
+const (
+	defaultModel         = "anthropic:claude-3-5-sonnet-latest"
+	defaultMessageWindow = 10
+
+	// defaultResponseTokens is the room kept in the context for a response
+	// when --max-tokens isn't set
+	defaultResponseTokens = 4096
+
+	// defaultToolConcurrency is the number of tool calls that run at once
+	defaultToolConcurrency = 4
+
+	// defaultToolTimeout is the number of seconds a tool call may take
+	defaultToolTimeout = 300
+
+	// defaultMaxSteps is the most responses of the model for one prompt
+	defaultMaxSteps = 20
+
+	// defaultMaxRepeatedCalls is the number of identical tool calls in a
+	// row that stops a prompt
+	defaultMaxRepeatedCalls = 3
+)
+
+// Backoff of the restarts of failed servers
 const (
 	initialBackoff = 1 * time.Second
 	maxBackoff     = 30 * time.Second
@@ -65,7 +103,7 @@ Example:
   mcphost -m openai:gpt-4
   mcphost -m google:gemini-2.0-flash`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runMCPHost(context.Background())
+		return runMCPHost(context.Background(), cmd.Flags())
 	},
 }
 
@@ -77,16 +115,29 @@ func Execute() {
 
 var debugMode bool
 var serverMode bool
+var streamResponses bool
+
+var sessionTTL time.Duration
+var resourceToolsFlag bool
+var rootFlags []string
+var mcpLogLevel string
+
+var (
+	profileFlag     string
+	sessionsDir     string
+	resumeSession   string
+	continueSession bool
+)
 
 func init() {
 	rootCmd.PersistentFlags().
-		StringVar(&configFile, "config", "", "config file (default is $HOME/.mcp.json)")
+		StringVar(&configFile, "config", "", "user config file, JSON or YAML (default is $HOME/.mcp.json)")
 	rootCmd.PersistentFlags().
-		StringVar(&systemPromptFile, "system-prompt", "", "system prompt json file")
+		StringVar(&systemPromptFile, "system-prompt", "", "system prompt file, text or JSON with a systemPrompt field")
 	rootCmd.PersistentFlags().
-		IntVar(&messageWindow, "message-window", 10, "number of messages to keep in context")
+		IntVar(&messageWindow, "message-window", defaultMessageWindow, "most messages to keep in context, on top of the token limit (0 for no limit)")
 	rootCmd.PersistentFlags().
-		StringVarP(&modelFlag, "model", "m", "anthropic:claude-3-5-sonnet-latest",
+		StringVarP(&modelFlag, "model", "m", defaultModel,
 			"model to use (format: provider:model, e.g. anthropic:claude-3-5-sonnet-latest or ollama:qwen2.5:3b)")
 
 	// Add debug flag
@@ -96,16 +147,48 @@ func init() {
 	rootCmd.PersistentFlags().
 		BoolVar(&serverMode, "server", false, "run as a server")
 
+	rootCmd.PersistentFlags().
+		DurationVar(&sessionTTL, "session-ttl", 30*time.Minute, "expire server sessions after this much idle time (0 disables expiry)")
+
+	rootCmd.PersistentFlags().
+		BoolVar(&streamResponses, "stream", true, "stream model responses as they are generated")
+
 	flags := rootCmd.PersistentFlags()
+	flags.StringArrayVar(&rootFlags, "root", nil, "directory to share with the MCP servers, can be repeated (default is the current directory)")
+	flags.StringVar(&mcpLogLevel, "mcp-log-level", "warning", "lowest level of MCP server log messages to show (debug, info, notice, warning, error, critical, alert, emergency or off)")
+	flags.BoolVar(&resourceToolsFlag, "resource-tools", false, "let the model list and read MCP resources through tools")
+	flags.StringVar(&profileFlag, "profile", "", "profile of the config to use")
+	flags.StringVar(&sessionsDir, "sessions-dir", "", "directory for saved sessions (default is $HOME/.mcphost/sessions)")
+	flags.StringVar(&resumeSession, "resume", "", "resume the saved session with this id")
+	flags.BoolVar(&continueSession, "continue", false, "continue the most recent saved session")
 	flags.StringVar(&openaiBaseURL, "openai-url", "", "base URL for OpenAI API (defaults to api.openai.com)")
 	flags.StringVar(&anthropicBaseURL, "anthropic-url", "", "base URL for Anthropic API (defaults to api.anthropic.com)")
 	flags.StringVar(&openaiAPIKey, "openai-api-key", "", "OpenAI API key")
 	flags.StringVar(&anthropicAPIKey, "anthropic-api-key", "", "Anthropic API key")
 	flags.StringVar(&googleAPIKey, "google-api-key", "", "Google (Gemini) API key")
+	flags.Float64Var(&temperatureFlag, "temperature", 0, "sampling temperature of the model, between 0 and 2")
+	flags.Float64Var(&topPFlag, "top-p", 0, "nucleus sampling probability of the model, between 0 and 1")
+	flags.IntVar(&maxTokensFlag, "max-tokens", 0, "most tokens in a response (default is 4096, or the provider's default)")
+	flags.IntVar(&toolConcurrency, "tool-concurrency", defaultToolConcurrency, "number of tool calls of one turn to run at the same time")
+	flags.IntVar(&toolTimeout, "tool-timeout", defaultToolTimeout, "seconds a tool call may take before it is cancelled")
+	flags.IntVar(&contextLength, "context-length", 0, "tokens the context of the model holds (default is taken from a table of known models)")
+	flags.IntVar(&maxResultTokens, "max-tool-result-tokens", 0, "most tokens of a tool result, longer ones are cut (default is a quarter of the context)")
+	flags.IntVar(&maxSteps, "max-steps", defaultMaxSteps, "most responses of the model for one prompt")
+	flags.IntVar(&maxRepeatedCalls, "max-repeated-tool-calls", defaultMaxRepeatedCalls, "number of identical tool calls in a row that stops a prompt")
 }
 
 // Add new function to create provider
 func createProvider(ctx context.Context, modelString, systemPrompt string) (llm.Provider, error) {
+	return createProviderWith(ctx, modelString, systemPrompt, generationParams)
+}
+
+// createProviderWith creates a provider with other generation parameters
+// than the ones mcphost was started with
+func createProviderWith(
+	ctx context.Context,
+	modelString, systemPrompt string,
+	params llm.GenerationParams,
+) (llm.Provider, error) {
 	parts := strings.SplitN(modelString, ":", 2)
 	if len(parts) < 2 {
 		return nil, fmt.Errorf(
@@ -118,7 +201,7 @@ func createProvider(ctx context.Context, modelString, systemPrompt string) (llm.
 	model := parts[1]
 
 	switch provider {
-	case "anthropic":
+	case providerAnthropic:
 		apiKey := anthropicAPIKey
 		if apiKey == "" {
 			apiKey = os.Getenv("ANTHROPIC_API_KEY")
@@ -126,15 +209,22 @@ func createProvider(ctx context.Context, modelString, systemPrompt string) (llm.
 
 		if apiKey == "" {
 			return nil, fmt.Errorf(
-				"Anthropic API key not provided. Use --anthropic-api-key flag or ANTHROPIC_API_KEY environment variable",
+				"Anthropic API key not provided. Use --anthropic-api-key flag, providers.anthropic.apiKey in the config or ANTHROPIC_API_KEY environment variable",
 			)
 		}
-		return anthropic.NewProvider(apiKey, anthropicBaseURL, model, systemPrompt), nil
+		return anthropic.NewProvider(apiKey, anthropicBaseURL, model, systemPrompt, params), nil
 
-	case "ollama":
-		return ollama.NewProvider(model, systemPrompt)
+	case providerOllama:
+		provider, err := ollama.NewProvider(ollamaBaseURL, model, systemPrompt, params)
+		if err != nil {
+			return nil, err
+		}
+		// The conversation is fitted to the context length, so Ollama is
+		// asked for a context that long
+		provider.SetContextLength(modelContextLength(modelString))
+		return provider, nil
 
-	case "openai":
+	case providerOpenAI:
 		apiKey := openaiAPIKey
 		if apiKey == "" {
 			apiKey = os.Getenv("OPENAI_API_KEY")
@@ -142,12 +232,12 @@ func createProvider(ctx context.Context, modelString, systemPrompt string) (llm.
 
 		if apiKey == "" {
 			return nil, fmt.Errorf(
-				"OpenAI API key not provided. Use --openai-api-key flag or OPENAI_API_KEY environment variable",
+				"OpenAI API key not provided. Use --openai-api-key flag, providers.openai.apiKey in the config or OPENAI_API_KEY environment variable",
 			)
 		}
-		return openai.NewProvider(apiKey, openaiBaseURL, model, systemPrompt), nil
+		return openai.NewProvider(apiKey, openaiBaseURL, model, systemPrompt, params), nil
 
-	case "google":
+	case providerGoogle:
 		apiKey := googleAPIKey
 		if apiKey == "" {
 			apiKey = os.Getenv("GOOGLE_API_KEY")
@@ -156,70 +246,13 @@ func createProvider(ctx context.Context, modelString, systemPrompt string) (llm.
 			// The project structure is provider specific, but Google calls this GEMINI_API_KEY in e.g. AI Studio. Support both.
 			apiKey = os.Getenv("GEMINI_API_KEY")
 		}
-		return google.NewProvider(ctx, apiKey, model, systemPrompt)
+		return google.NewProvider(ctx, apiKey, model, systemPrompt, params)
 
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", provider)
 	}
 }
 
-func pruneMessages(messages []history.HistoryMessage) []history.HistoryMessage {
-	if len(messages) <= messageWindow {
-		return messages
-	}
-
-	// Keep only the most recent messages based on window size
-	messages = messages[len(messages)-messageWindow:]
-
-	// Handle messages
-	toolUseIds := make(map[string]bool)
-	toolResultIds := make(map[string]bool)
-
-	// First pass: collect all tool use and result IDs
-	for _, msg := range messages {
-		for _, block := range msg.Content {
-			if block.Type == "tool_use" {
-				toolUseIds[block.ID] = true
-			} else if block.Type == "tool_result" {
-				toolResultIds[block.ToolUseID] = true
-			}
-		}
-	}
-
-	// Second pass: filter out orphaned tool calls/results
-	var prunedMessages []history.HistoryMessage
-	for _, msg := range messages {
-		var prunedBlocks []history.ContentBlock
-		for _, block := range msg.Content {
-			keep := true
-			if block.Type == "tool_use" {
-				keep = toolResultIds[block.ID]
-			} else if block.Type == "tool_result" {
-				keep = toolUseIds[block.ToolUseID]
-			}
-			if keep {
-				prunedBlocks = append(prunedBlocks, block)
-			}
-		}
-		// Only include messages that have content or are not assistant messages
-		if (len(prunedBlocks) > 0 && msg.Role == "assistant") ||
-			msg.Role != "assistant" {
-			hasTextBlock := false
-			for _, block := range msg.Content {
-				if block.Type == "text" {
-					hasTextBlock = true
-					break
-				}
-			}
-			if len(prunedBlocks) > 0 || hasTextBlock {
-				msg.Content = prunedBlocks
-				prunedMessages = append(prunedMessages, msg)
-			}
-		}
-	}
-	return prunedMessages
-}
-
 func getTerminalWidth() int {
 	width, _, err := term.GetSize(int(os.Stdout.Fd()))
 	if err != nil {
@@ -242,238 +275,134 @@ func updateRenderer() error {
 	return err
 }
 
-// Method implementations for simpleMessage
+// runPrompt sends a prompt to the model and shows its responses and the
+// tools it calls as they happen
 func runPrompt(
 	ctx context.Context,
 	provider llm.Provider,
 	mcpClients map[string]mcpclient.MCPClient,
+	approver *toolApprover,
 	tools []llm.Tool,
 	prompt string,
 	messages *[]history.HistoryMessage,
 ) error {
-	// Display the user's prompt if it's not empty (i.e., not a tool response)
 	if prompt != "" {
 		fmt.Printf("\n%s\n", promptStyle.Render("You: "+prompt))
-		*messages = append(
-			*messages,
-			history.HistoryMessage{
-				Role: "user",
-				Content: []history.ContentBlock{{
-					Type: "text",
-					Text: prompt,
-				}},
-			},
-		)
-	}
-
-	var message llm.Message
-	var err error
-	backoff := initialBackoff
-	retries := 0
-
-	// Convert MessageParam to llm.Message for provider
-	// Messages already implement llm.Message interface
-	llmMessages := make([]llm.Message, len(*messages))
-	for i := range *messages {
-		llmMessages[i] = &(*messages)[i]
 	}
 
-	for {
-		action := func() {
-			message, err = provider.CreateMessage(
-				ctx,
-				prompt,
-				llmMessages,
-				tools,
-			)
-		}
-		_ = spinner.New().Title("Thinking...").Action(action).Run()
-
-		if err != nil {
-			// Check if it's an overloaded error
-			if strings.Contains(err.Error(), "overloaded_error") {
-				if retries >= maxRetries {
-					return fmt.Errorf(
-						"claude is currently overloaded. please wait a few minutes and try again",
-					)
-				}
-
-				log.Warn("Claude is overloaded, backing off...",
-					"attempt", retries+1,
-					"backoff", backoff.String())
+	a := agent.New(agent.Options{
+		Provider: provider,
+		Clients:  mcpClients,
+		Tools:    func() []llm.Tool { return tools },
+		Executor: &hostExecutor{
+			config:     approver.config,
+			mcpClients: mcpClients,
+			approver:   approver,
+			spinner:    true,
+		},
+		Stream:  streamResponses,
+		History: *messages,
+
+		MaxSteps:            maxSteps,
+		MaxRepeatedCalls:    maxRepeatedCalls,
+		ContextTokens:       contextBudget.conversation,
+		MaxMessages:         messageWindow,
+		MaxToolResultTokens: contextBudget.toolResult,
+	})
 
-				time.Sleep(backoff)
-				backoff *= 2
-				if backoff > maxBackoff {
-					backoff = maxBackoff
-				}
-				retries++
-				continue
-			}
-			// If it's not an overloaded error, return the error immediately
-			return err
-		}
-		// If we got here, the request succeeded
-		break
+	view := &promptView{}
+	_, err := a.Run(ctx, prompt, view.handle)
+	view.stopSpinner()
+	*messages = a.History()
+	if err != nil {
+		return err
 	}
 
-	var messageContent []history.ContentBlock
-
-	// Handle the message response
-	if str, err := renderer.Render("\nAssistant: "); message.GetContent() != "" && err == nil {
-		fmt.Print(str)
-	}
+	fmt.Println() // Add spacing
+	return nil
+}
 
-	toolResults := []history.ContentBlock{}
-	messageContent = []history.ContentBlock{}
+// promptView shows the events of a prompt in the terminal. A spinner is
+// shown while the model thinks, and streamed text is printed as it comes.
+type promptView struct {
+	stop     func()
+	printing bool
+}
 
-	// Add text content
-	if message.GetContent() != "" {
-		if err := updateRenderer(); err != nil {
-			return fmt.Errorf("error updating renderer: %v", err)
-		}
-		str, err := renderer.Render(message.GetContent() + "\n")
-		if err != nil {
-			log.Error("Failed to render response", "error", err)
-			fmt.Print(message.GetContent() + "\n")
-		} else {
-			fmt.Print(str)
-		}
-		messageContent = append(messageContent, history.ContentBlock{
-			Type: "text",
-			Text: message.GetContent(),
-		})
+func (v *promptView) stopSpinner() {
+	if v.stop != nil {
+		v.stop()
+		v.stop = nil
 	}
+}
 
-	// Handle tool calls
-	for _, toolCall := range message.GetToolCalls() {
-		log.Info("🔧 Using tool", "name", toolCall.GetName())
-
-		input, _ := json.Marshal(toolCall.GetArguments())
-		messageContent = append(messageContent, history.ContentBlock{
-			Type:  "tool_use",
-			ID:    toolCall.GetID(),
-			Name:  toolCall.GetName(),
-			Input: input,
-		})
-
-		// Log usage statistics if available
-		inputTokens, outputTokens := message.GetUsage()
-		if inputTokens > 0 || outputTokens > 0 {
-			log.Info("Usage statistics",
-				"input_tokens", inputTokens,
-				"output_tokens", outputTokens,
-				"total_tokens", inputTokens+outputTokens)
-		}
-
-		parts := strings.Split(toolCall.GetName(), "__")
-		if len(parts) != 2 {
-			fmt.Printf(
-				"Error: Invalid tool name format: %s\n",
-				toolCall.GetName(),
-			)
-			continue
-		}
-
-		serverName, toolName := parts[0], parts[1]
-		mcpClient, ok := mcpClients[serverName]
-		if !ok {
-			fmt.Printf("Error: Server not found: %s\n", serverName)
-			continue
+func (v *promptView) handle(event agent.Event) {
+	switch e := event.(type) {
+	case agent.ThinkingEvent:
+		if v.stop == nil {
+			v.stop = startSpinner("Thinking...")
 		}
 
-		var toolArgs map[string]interface{}
-		if err := json.Unmarshal(input, &toolArgs); err != nil {
-			fmt.Printf("Error parsing tool arguments: %v\n", err)
-			continue
+	case agent.TextEvent:
+		v.stopSpinner()
+		if !streamResponses {
+			v.printText(e.Text)
+			return
 		}
-
-		var toolResultPtr *mcp.CallToolResult
-		action := func() {
-			req := mcp.CallToolRequest{}
-			req.Params.Name = toolName
-			req.Params.Arguments = toolArgs
-			toolResultPtr, err = mcpClient.CallTool(
-				context.Background(),
-				req,
-			)
+		if !v.printing {
+			if str, err := renderer.Render("\nAssistant: "); err == nil {
+				fmt.Print(str)
+			}
+			v.printing = true
 		}
-		_ = spinner.New().
-			Title(fmt.Sprintf("Running tool %s...", toolName)).
-			Action(action).
-			Run()
+		fmt.Print(e.Text)
 
-		if err != nil {
-			errMsg := fmt.Sprintf(
-				"Error calling tool %s: %v",
-				toolName,
-				err,
-			)
-			fmt.Printf("\n%s\n", errorStyle.Render(errMsg))
-
-			// Add error message as tool result
-			toolResults = append(toolResults, history.ContentBlock{
-				Type:      "tool_result",
-				ToolUseID: toolCall.GetID(),
-				Content: []history.ContentBlock{{
-					Type: "text",
-					Text: errMsg,
-				}},
-			})
-			continue
+	case agent.ResponseEvent:
+		v.stopSpinner()
+		if v.printing {
+			fmt.Println()
+			v.printing = false
 		}
 
-		toolResult := *toolResultPtr
+	case agent.UsageEvent:
+		log.Info("Usage statistics",
+			"input_tokens", e.InputTokens,
+			"output_tokens", e.OutputTokens,
+			"total_tokens", e.InputTokens+e.OutputTokens)
 
-		if toolResult.Content != nil {
-			log.Debug("raw tool result content", "content", toolResult.Content)
+	case agent.ToolStartEvent:
+		log.Info("🔧 Using tool", "name", e.Name)
 
-			// Create the tool result block
-			resultBlock := history.ContentBlock{
-				Type:      "tool_result",
-				ToolUseID: toolCall.GetID(),
-				Content:   toolResult.Content,
-			}
-
-			// Extract text content
-			var resultText string
-			// Handle array content directly since we know it's []interface{}
-			for _, item := range toolResult.Content {
-				if contentMap, ok := item.(mcp.TextContent); ok {
-					resultText += fmt.Sprintf("%v ", contentMap.Text)
-				}
-			}
-
-			resultBlock.Text = strings.TrimSpace(resultText)
-			log.Debug("created tool result block",
-				"block", resultBlock,
-				"tool_id", toolCall.GetID())
-
-			toolResults = append(toolResults, resultBlock)
+	case agent.ToolEndEvent:
+		if e.IsError {
+			fmt.Printf("\n%s\n", errorStyle.Render(e.Result))
 		}
-	}
-
-	*messages = append(*messages, history.HistoryMessage{
-		Role:    message.GetRole(),
-		Content: messageContent,
-	})
 
-	if len(toolResults) > 0 {
-		for _, toolResult := range toolResults {
-			*messages = append(*messages, history.HistoryMessage{
-				Role:    "tool",
-				Content: []history.ContentBlock{toolResult},
-			})
+	case agent.ErrorEvent:
+		if e.Retry {
+			log.Warn("Model is overloaded, backing off...", "backoff", e.Backoff.String())
 		}
-		// Make another call to get Claude's response to the tool results
-		return runPrompt(ctx, provider, mcpClients, tools, "", messages)
 	}
+}
 
-	fmt.Println() // Add spacing
-	return nil
+// printText renders a whole response of the model as markdown
+func (v *promptView) printText(text string) {
+	if str, err := renderer.Render("\nAssistant: "); err == nil {
+		fmt.Print(str)
+	}
+	if err := updateRenderer(); err != nil {
+		log.Error("Failed to update renderer", "error", err)
+	}
+	str, err := renderer.Render(text + "\n")
+	if err != nil {
+		log.Error("Failed to render response", "error", err)
+		fmt.Print(text + "\n")
+		return
+	}
+	fmt.Print(str)
 }
 
-func runMCPHost(ctx context.Context) error {
+func runMCPHost(ctx context.Context, flags *pflag.FlagSet) error {
 	// Set up logging based on debug flag
 	if debugMode {
 		log.SetLevel(log.DebugLevel)
@@ -484,7 +413,19 @@ func runMCPHost(ctx context.Context) error {
 		log.SetReportCaller(false)
 	}
 
-	systemPrompt, err := loadSystemPrompt(systemPromptFile)
+	if err := validateLogLevel(mcpLogLevel); err != nil {
+		return err
+	}
+
+	mcpConfig, err := loadMCPConfig(flags, "")
+	if err != nil {
+		return fmt.Errorf("error loading MCP config: %v", err)
+	}
+	if err := useSettings(mcpConfig); err != nil {
+		return fmt.Errorf("error loading settings: %v", err)
+	}
+
+	systemPrompt, err := mcpConfig.systemPrompt()
 	if err != nil {
 		return fmt.Errorf("error loading system prompt: %v", err)
 	}
@@ -500,17 +441,18 @@ func runMCPHost(ctx context.Context) error {
 	log.Info("Model loaded",
 		"provider", provider.Name(),
 		"model", parts[1])
+	useContextBudget(provider, systemPrompt)
 
-	mcpConfig, err := loadMCPConfig()
-	if err != nil {
-		return fmt.Errorf("error loading MCP config: %v", err)
-	}
+	// Requests can only be confirmed by the user in the interactive loop
+	sampler := newSamplingHandler(mcpConfig, !serverMode)
 
-	mcpClients, err := createMCPClients(mcpConfig)
+	roots, err := newRootsHandler(mcpConfig)
 	if err != nil {
-		return fmt.Errorf("error creating MCP clients: %v", err)
+		return fmt.Errorf("error loading roots: %v", err)
 	}
 
+	mcpClients := createMCPClients(mcpConfig, mcpConfig.MCPServers, sampler, roots)
+
 	defer func() {
 		log.Info("Shutting down MCP servers...")
 		for name, client := range mcpClients {
@@ -522,45 +464,28 @@ func runMCPHost(ctx context.Context) error {
 		}
 	}()
 
-	for name := range mcpClients {
-		log.Info("Server connected", "name", name)
-	}
+	tools := newToolSet(mcpConfig)
+	tools.loadAll(ctx, mcpClients)
 
-	var allTools []llm.Tool
-	for serverName, mcpClient := range mcpClients {
-		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-		toolsResult, err := mcpClient.ListTools(ctx, mcp.ListToolsRequest{})
-		cancel()
-
-		if err != nil {
-			log.Error(
-				"Error fetching tools",
-				"server",
-				serverName,
-				"error",
-				err,
-			)
-			continue
-		}
-
-		serverTools := mcpToolsToAnthropicTools(serverName, toolsResult.Tools)
-		allTools = append(allTools, serverTools...)
-		log.Info(
-			"Tools loaded",
-			"server",
-			serverName,
-			"count",
-			len(toolsResult.Tools),
-		)
+	if resourceToolsFlag {
+		tools.addHostTools(resourceTools()...)
 	}
 
+	watchNotifications(mcpClients, tools)
+
 	if err := updateRenderer(); err != nil {
 		return fmt.Errorf("error initializing renderer: %v", err)
 	}
 
-	messages := make([]history.HistoryMessage, 0)
+	// Tool calls can only be confirmed by the user in the interactive loop
+	approver := newToolApprover(mcpConfig, !serverMode)
 
 	hostLoop := func() error {
+		chat, err := openChatSession()
+		if err != nil {
+			return fmt.Errorf("error opening session: %v", err)
+		}
+
 		// Main interaction loop
 		for {
 			var prompt string
@@ -585,12 +510,46 @@ func runMCPHost(ctx context.Context) error {
 				continue
 			}
 
+			// Switching profiles replaces the provider, so it is handled here
+			// rather than with the other slash commands
+			if fields := strings.Fields(prompt); len(fields) > 0 && strings.ToLower(fields[0]) == "/profile" {
+				if len(fields) == 1 {
+					handleProfilesCommand(mcpConfig)
+					continue
+				}
+				switched, err := switchProfile(ctx, flags, fields[1], mcpConfig, mcpClients, tools, sampler, roots)
+				if err != nil {
+					fmt.Printf("\n%s\n\n", errorStyle.Render(err.Error()))
+					continue
+				}
+				provider = switched
+				chat.current.Model = modelFlag
+				fmt.Printf("\nSwitched to profile %s with %s\n\n", fields[1], modelFlag)
+				continue
+			}
+
+			// Messages to add to the history before the prompt is sent
+			var pending []history.HistoryMessage
+
+			// Prompts of the MCP servers are run as /server:prompt
+			if serverName, promptName, rest, ok := parsePromptCommand(prompt, mcpClients); ok {
+				pending, err = runServerPrompt(ctx, mcpClients[serverName], promptName, rest)
+				if err != nil {
+					if !errors.Is(err, huh.ErrUserAborted) {
+						fmt.Printf("\n%s\n\n", errorStyle.Render(err.Error()))
+					}
+					continue
+				}
+				prompt = ""
+			}
+
 			// Handle slash commands
 			handled, err := handleSlashCommand(
 				prompt,
 				mcpConfig,
 				mcpClients,
-				messages,
+				chat,
+				roots,
 			)
 			if err != nil {
 				return err
@@ -599,10 +558,30 @@ func runMCPHost(ctx context.Context) error {
 				continue
 			}
 
-			if len(messages) > 0 {
-				messages = pruneMessages(messages)
+			attached, err := attachResources(ctx, mcpClients, prompt)
+			if err != nil {
+				fmt.Printf("\n%s\n\n", errorStyle.Render(err.Error()))
+				continue
+			}
+			if attached != nil {
+				pending = append(pending, *attached)
+			}
+
+			messages := &chat.current.Messages
+			*messages = append(*messages, pending...)
+			promptCtx, stop := interruptible(ctx)
+			err = runPrompt(promptCtx, provider, mcpClients, approver, tools.all(), prompt, messages)
+			stop()
+			// Save even if the turn failed so nothing before the error is lost
+			chat.save()
+			if errors.Is(err, errInterrupted) {
+				fmt.Printf("\n%s\n\n", errorStyle.Render("Cancelled."))
+				continue
+			}
+			if errors.Is(err, agent.ErrMaxSteps) || errors.Is(err, agent.ErrRepeatedToolCall) {
+				fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Stopped: %v.", err)))
+				continue
 			}
-			err = runPrompt(ctx, provider, mcpClients, allTools, prompt, &messages)
 			if err != nil {
 				return err
 			}
@@ -610,7 +589,7 @@ func runMCPHost(ctx context.Context) error {
 	}
 
 	if serverMode {
-		err = runServer(ctx, provider, mcpClients, allTools)
+		err = runServer(ctx, provider, mcpClients, approver, tools)
 	} else {
 		err = hostLoop()
 	}
@@ -618,7 +597,8 @@ func runMCPHost(ctx context.Context) error {
 	return err
 }
 
-// loadSystemPrompt loads the system prompt from a JSON file
+// loadSystemPrompt loads the system prompt from a JSON file with a
+// systemPrompt field, or from a text file holding just the prompt
 func loadSystemPrompt(filePath string) (string, error) {
 	if filePath == "" {
 		return "", nil
@@ -629,6 +609,10 @@ func loadSystemPrompt(filePath string) (string, error) {
 		return "", fmt.Errorf("error reading config file: %v", err)
 	}
 
+	if !strings.EqualFold(filepath.Ext(filePath), ".json") {
+		return strings.TrimSpace(string(data)), nil
+	}
+
 	// Parse only the systemPrompt field
 	var config struct {
 		SystemPrompt string `json:"systemPrompt"`
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// rootsHandler keeps the directories the user works in and shares them with
+// the MCP servers as roots
+type rootsHandler struct {
+	mu    sync.RWMutex
+	roots []string
+}
+
+// newRootsHandler starts with the roots of the config, which --root
+// replaces. Without any, the current directory is the only root.
+func newRootsHandler(config *MCPConfig) (*rootsHandler, error) {
+	paths := config.Roots
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	h := &rootsHandler{}
+	for _, path := range paths {
+		root, err := resolveRoot(path)
+		if err != nil {
+			return nil, err
+		}
+		if !h.contains(root) {
+			h.roots = append(h.roots, root)
+		}
+	}
+	return h, nil
+}
+
+// absRoot returns the absolute path of a root, with ~ expanded to the home
+// directory
+func absRoot(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error getting home directory: %w", err)
+		}
+		path = filepath.Join(homeDir, path[1:])
+	}
+	return filepath.Abs(path)
+}
+
+// resolveRoot turns a path into the absolute path of a directory
+func resolveRoot(path string) (string, error) {
+	root, err := absRoot(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid root %s: %w", path, err)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid root %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("invalid root %s: not a directory", path)
+	}
+	return root, nil
+}
+
+func (h *rootsHandler) contains(root string) bool {
+	for _, r := range h.roots {
+		if r == root {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *rootsHandler) list() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return append([]string(nil), h.roots...)
+}
+
+// add adds a root. It reports false if the root was already there.
+func (h *rootsHandler) add(path string) (string, bool, error) {
+	root, err := resolveRoot(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.contains(root) {
+		return root, false, nil
+	}
+	h.roots = append(h.roots, root)
+	return root, true, nil
+}
+
+// remove removes a root. It reports false if there was no such root.
+func (h *rootsHandler) remove(path string) (string, bool) {
+	root, err := absRoot(path)
+	if err != nil {
+		root = path
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, r := range h.roots {
+		if r == root {
+			h.roots = append(h.roots[:i], h.roots[i+1:]...)
+			return root, true
+		}
+	}
+	return root, false
+}
+
+// ListRoots answers the roots/list requests of the MCP servers
+func (h *rootsHandler) ListRoots(
+	ctx context.Context,
+	request mcp.ListRootsRequest,
+) (*mcp.ListRootsResult, error) {
+	// The roots are always a list, even when there are none
+	roots := []mcp.Root{}
+	for _, root := range h.list() {
+		roots = append(roots, mcp.Root{
+			URI:  (&url.URL{Scheme: "file", Path: filepath.ToSlash(root)}).String(),
+			Name: filepath.Base(root),
+		})
+	}
+	return &mcp.ListRootsResult{Roots: roots}, nil
+}
+
+// notifyRootsChanged tells every server that the roots have changed
+func notifyRootsChanged(mcpClients map[string]mcpclient.MCPClient) {
+	for name, mcpClient := range mcpClients {
+		notifier, ok := mcpClient.(interface {
+			RootListChanges(ctx context.Context) error
+		})
+		if !ok {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := notifier.RootListChanges(ctx); err != nil {
+			log.Warn("Failed to notify server of roots change", "server", name, "error", err)
+		}
+		cancel()
+	}
+}
+
+func handleRootsCommand(
+	roots *rootsHandler,
+	mcpClients map[string]mcpclient.MCPClient,
+	args []string,
+) {
+	if len(args) == 0 {
+		if err := updateRenderer(); err != nil {
+			fmt.Printf(
+				"\n%s\n",
+				errorStyle.Render(fmt.Sprintf("Error updating renderer: %v", err)),
+			)
+			return
+		}
+
+		var markdown strings.Builder
+		markdown.WriteString("# Roots\n\n")
+		for _, root := range roots.list() {
+			markdown.WriteString(fmt.Sprintf("- `%s`\n", root))
+		}
+		if len(roots.list()) == 0 {
+			markdown.WriteString("No roots.\n")
+		}
+		markdown.WriteString("\nUse `/roots add path` and `/roots remove path` to change them.\n")
+
+		rendered, err := renderer.Render(markdown.String())
+		if err != nil {
+			fmt.Printf(
+				"\n%s\n",
+				errorStyle.Render(fmt.Sprintf("Error rendering roots: %v", err)),
+			)
+			return
+		}
+		fmt.Print(rendered)
+		return
+	}
+
+	if len(args) != 2 || (args[0] != "add" && args[0] != "remove") {
+		fmt.Printf("\n%s\n\n", errorStyle.Render("Usage: /roots [add|remove path]"))
+		return
+	}
+
+	if args[0] == "add" {
+		root, added, err := roots.add(args[1])
+		if err != nil {
+			fmt.Printf("\n%s\n\n", errorStyle.Render(err.Error()))
+			return
+		}
+		if !added {
+			fmt.Printf("\n%s\n\n", promptStyle.Render("Already a root: "+root))
+			return
+		}
+		fmt.Printf("\n%s\n\n", promptStyle.Render("Root added: "+root))
+	} else {
+		root, removed := roots.remove(args[1])
+		if !removed {
+			fmt.Printf("\n%s\n\n", errorStyle.Render("Not a root: "+root))
+			return
+		}
+		fmt.Printf("\n%s\n\n", promptStyle.Render("Root removed: "+root))
+	}
+
+	notifyRootsChanged(mcpClients)
+}
@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/mark3labs/mcphost/pkg/history"
+	"github.com/mark3labs/mcphost/pkg/llm"
+)
+
+// samplingBytesPerToken estimates the input tokens of sampling requests
+// before they run. It is lower than what providers take, so that the
+// estimate errs on the side of more tokens.
+const samplingBytesPerToken = 3
+
+// samplingHandler serves the sampling requests of MCP servers with the model
+// mcphost was started with. Requests are approved like tool calls and
+// counted against the limits of each server.
+type samplingHandler struct {
+	config      *MCPConfig
+	interactive bool
+
+	mu             sync.Mutex
+	requests       map[string]int
+	tokens         map[string]int
+	allowedServers map[string]bool
+}
+
+func newSamplingHandler(config *MCPConfig, interactive bool) *samplingHandler {
+	return &samplingHandler{
+		config:         config,
+		interactive:    interactive,
+		requests:       make(map[string]int),
+		tokens:         make(map[string]int),
+		allowedServers: make(map[string]bool),
+	}
+}
+
+func (h *samplingHandler) options(serverName string) SamplingOptions {
+	if options := h.config.serverOptions(serverName).Sampling; options != nil {
+		return *options
+	}
+	return SamplingOptions{}
+}
+
+// enabled reports whether sampling is offered to a server. Only servers
+// with sampling options in the config get it.
+func (h *samplingHandler) enabled(serverName string) bool {
+	options := h.config.serverOptions(serverName).Sampling
+	return options != nil && options.Approval != policyDeny
+}
+
+// forServer returns the handler for the sampling requests of one server
+func (h *samplingHandler) forServer(serverName string) mcpclient.SamplingHandler {
+	return &serverSampler{handler: h, serverName: serverName}
+}
+
+type serverSampler struct {
+	handler    *samplingHandler
+	serverName string
+}
+
+func (s *serverSampler) CreateMessage(
+	ctx context.Context,
+	request mcp.CreateMessageRequest,
+) (*mcp.CreateMessageResult, error) {
+	return s.handler.createMessage(ctx, s.serverName, request.CreateMessageParams)
+}
+
+func (h *samplingHandler) createMessage(
+	ctx context.Context,
+	serverName string,
+	params mcp.CreateMessageParams,
+) (*mcp.CreateMessageResult, error) {
+	options := h.options(serverName)
+
+	// Without a user to ask, only servers allowed to sample without asking
+	// are served
+	if options.Approval != policyAuto && !h.interactive {
+		log.Warn("Sampling request needs approval, but nobody can approve it", "server", serverName)
+		return nil, fmt.Errorf("the sampling request needs the approval of the user, who can't be asked")
+	}
+
+	messages := make([]history.HistoryMessage, 0, len(params.Messages))
+	inputTokens := llm.EstimateTokens(params.SystemPrompt, samplingBytesPerToken)
+	for _, message := range params.Messages {
+		text := samplingContentText(message.Content)
+		inputTokens += llm.EstimateTokens(text, samplingBytesPerToken)
+		messages = append(messages, history.HistoryMessage{
+			Role: string(message.Role),
+			Content: []history.ContentBlock{{
+				Type: "text",
+				Text: text,
+			}},
+		})
+	}
+
+	// The request is counted, and its tokens reserved, before it runs so
+	// that concurrent requests can't go past the limits
+	maxTokens := params.MaxTokens
+	h.mu.Lock()
+	if options.MaxRequests > 0 && h.requests[serverName] >= options.MaxRequests {
+		h.mu.Unlock()
+		log.Warn("Sampling request limit reached", "server", serverName)
+		return nil, fmt.Errorf("sampling request limit of %d reached", options.MaxRequests)
+	}
+	if options.MaxTokens > 0 {
+		remaining := options.MaxTokens - h.tokens[serverName] - inputTokens
+		if remaining <= 0 {
+			h.mu.Unlock()
+			log.Warn("Sampling token limit reached", "server", serverName)
+			return nil, fmt.Errorf("sampling token limit of %d reached", options.MaxTokens)
+		}
+		if maxTokens <= 0 || maxTokens > remaining {
+			maxTokens = remaining
+		}
+	}
+	reserved := inputTokens + maxTokens
+	h.requests[serverName]++
+	h.tokens[serverName] += reserved
+	allowed := h.allowedServers[serverName]
+	h.mu.Unlock()
+
+	// Once the request is done, the tokens it used count instead of the
+	// reserved ones. Requests that fail count nothing.
+	used := 0
+	defer func() {
+		h.mu.Lock()
+		h.tokens[serverName] += used - reserved
+		h.mu.Unlock()
+	}()
+
+	if options.Approval != policyAuto && !allowed {
+		var approved bool
+		var err error
+		withTerminal(func() {
+			approved, err = h.ask(serverName, params, messages)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !approved {
+			return nil, fmt.Errorf("the user denied the sampling request")
+		}
+	}
+
+	// The server brings its own system prompt and response length, so
+	// requests get a provider of their own
+	settingsMu.RLock()
+	model, generation := modelFlag, generationParams
+	if maxTokens > 0 {
+		generation.MaxTokens = maxTokens
+	}
+	provider, err := createProviderWith(ctx, model, params.SystemPrompt, generation)
+	settingsMu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("error creating provider: %w", err)
+	}
+
+	llmMessages := make([]llm.Message, len(messages))
+	for i := range messages {
+		llmMessages[i] = &messages[i]
+	}
+
+	log.Info("🧪 Sampling", "server", serverName, "messages", len(messages))
+	message, err := provider.CreateMessage(ctx, "", llmMessages, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating message: %w", err)
+	}
+
+	usedInput, usedOutput := message.GetUsage()
+	used = usedInput + usedOutput
+	log.Debug("Sampling usage",
+		"server", serverName,
+		"input_tokens", usedInput,
+		"output_tokens", usedOutput)
+
+	return &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{
+			Role:    mcp.RoleAssistant,
+			Content: mcp.NewTextContent(message.GetContent()),
+		},
+		Model:      model,
+		StopReason: "endTurn",
+	}, nil
+}
+
+func (h *samplingHandler) ask(
+	serverName string,
+	params mcp.CreateMessageParams,
+	messages []history.HistoryMessage,
+) (bool, error) {
+	var request strings.Builder
+	fmt.Fprintf(&request, "%s %s\n", toolNameStyle.Render("Server:"), serverName)
+	fmt.Fprintf(&request, "%s %d\n", toolNameStyle.Render("Max tokens:"), params.MaxTokens)
+	if params.SystemPrompt != "" {
+		fmt.Fprintf(&request, "%s %s\n", toolNameStyle.Render("System prompt:"), params.SystemPrompt)
+	}
+	for _, message := range messages {
+		fmt.Fprintf(&request, "\n%s %s", toolNameStyle.Render(message.Role+":"), message.GetContent())
+	}
+	fmt.Printf("\n%s\n", approvalStyle.Render(request.String()))
+
+	var choice string
+	err := huh.NewForm(huh.NewGroup(huh.NewSelect[string]().
+		Title(fmt.Sprintf("Allow %s to use the model?", serverName)).
+		Options(
+			huh.NewOption("Approve", approveOnce),
+			huh.NewOption("Deny", approveDeny),
+			huh.NewOption(fmt.Sprintf("Always allow %s this session", serverName), approveAllServer),
+		).
+		Value(&choice)),
+	).WithWidth(getTerminalWidth()).
+		WithTheme(huh.ThemeCharm()).
+		Run()
+	if err != nil {
+		if errors.Is(err, huh.ErrUserAborted) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	switch choice {
+	case approveOnce:
+		return true, nil
+	case approveAllServer:
+		h.mu.Lock()
+		h.allowedServers[serverName] = true
+		h.mu.Unlock()
+		return true, nil
+	}
+	return false, nil
+}
+
+// samplingContentText returns the content of a sampling message as text.
+// Content that can't be shown as text is replaced by a short note.
+func samplingContentText(content interface{}) string {
+	switch c := content.(type) {
+	case mcp.TextContent:
+		return c.Text
+	case mcp.ImageContent:
+		return fmt.Sprintf("[image, type %s]", c.MIMEType)
+	case mcp.AudioContent:
+		return fmt.Sprintf("[audio, type %s]", c.MIMEType)
+	}
+	return fmt.Sprintf("%v", content)
+}
@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configError is an error in the value at a path of the config, such as
+// mcpServers.github.command. The line it is about is found from the path.
+type configError struct {
+	path []string
+	err  error
+}
+
+func configErrorf(path []string, format string, args ...interface{}) error {
+	return &configError{path: path, err: fmt.Errorf(format, args...)}
+}
+
+func (e *configError) Error() string {
+	if len(e.path) == 0 {
+		return e.err.Error()
+	}
+	return joinPath(e.path) + ": " + e.err.Error()
+}
+
+func (e *configError) Unwrap() error {
+	return e.err
+}
+
+// joinPath writes a path as keys joined with dots, with list indexes in
+// brackets
+func joinPath(path []string) string {
+	var b strings.Builder
+	for i, key := range path {
+		if _, ok := pathIndex(key); i > 0 && !ok {
+			b.WriteByte('.')
+		}
+		b.WriteString(key)
+	}
+	return b.String()
+}
+
+// pathIndex returns the list index of a path element such as [2]
+func pathIndex(key string) (int, bool) {
+	digits, ok := strings.CutPrefix(key, "[")
+	if !ok {
+		return 0, false
+	}
+	digits, ok = strings.CutSuffix(digits, "]")
+	if !ok {
+		return 0, false
+	}
+	index, err := strconv.Atoi(digits)
+	return index, err == nil && index >= 0
+}
+
+// appendPath returns a new path, so that paths built in a loop don't share
+// their backing array
+func appendPath(path []string, keys ...string) []string {
+	return append(append([]string{}, path...), keys...)
+}
+
+func isYAMLFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// decodeConfig parses a JSON or YAML config file, checks it against the
+// schema of the config and decodes it. Errors give the line and column of
+// the value they are about.
+func decodeConfig(filename string, data []byte, config *MCPConfig, project bool) error {
+	yamlFile := isYAMLFile(filename)
+
+	// YAML accepts more than JSON does, so JSON files are parsed as JSON
+	// first
+	if !yamlFile {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				// The offset is just past the byte the error is about
+				line, column := offsetPosition(data, syntaxErr.Offset-1)
+				return fmt.Errorf("error parsing config file %s:%d:%d: %w", filename, line, column, err)
+			}
+			return fmt.Errorf("error parsing config file %s: %w", filename, err)
+		}
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", filename, err)
+	}
+
+	positioned := func(err error) error {
+		var pathErr *configError
+		if errors.As(err, &pathErr) {
+			if node := findNode(&root, pathErr.path); node != nil {
+				return fmt.Errorf("error in config file %s:%d:%d: %w", filename, node.Line, node.Column, err)
+			}
+		}
+		return fmt.Errorf("error in config file %s: %w", filename, err)
+	}
+
+	if err := checkSchema(&root, reflect.TypeOf(*config), nil); err != nil {
+		return positioned(err)
+	}
+
+	if yamlFile {
+		var value interface{}
+		if err := root.Decode(&value); err != nil {
+			return fmt.Errorf("error parsing config file %s: %w", filename, err)
+		}
+		var err error
+		if data, err = json.Marshal(value); err != nil {
+			return fmt.Errorf("error parsing config file %s: %w", filename, err)
+		}
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", filename, err)
+	}
+
+	if err := validateMCPConfig(config); err != nil {
+		return positioned(err)
+	}
+	if project {
+		if err := validateProjectConfig(config); err != nil {
+			return positioned(err)
+		}
+	}
+	return nil
+}
+
+// offsetPosition turns a byte offset into the line and column of the byte
+func offsetPosition(data []byte, offset int64) (line, column int) {
+	offset = max(0, min(offset, int64(len(data))))
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+var serverConfigWrapperType = reflect.TypeOf(ServerConfigWrapper{})
+
+// checkSchema checks a document against the Go type it is decoded into, so
+// that misspelled keys and values of the wrong type are reported instead of
+// being ignored
+func checkSchema(node *yaml.Node, t reflect.Type, path []string) error {
+	switch node.Kind {
+	case 0:
+		// An empty document
+		return nil
+	case yaml.DocumentNode:
+		return checkSchema(node.Content[0], t, path)
+	case yaml.AliasNode:
+		return checkSchema(node.Alias, t, path)
+	}
+	if node.Tag == "!!null" {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == serverConfigWrapperType {
+		return checkServerSchema(node, path)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return configErrorf(path, "expected an object")
+		}
+		fields := schemaFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				return configErrorf(appendPath(path, key.Value), "unknown key")
+			}
+			if err := checkSchema(value, fieldType, appendPath(path, key.Value)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return configErrorf(path, "expected an object")
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Tag != "!!str" {
+				return configErrorf(appendPath(path, key.Value), "keys must be strings")
+			}
+			if err := checkSchema(value, t.Elem(), appendPath(path, key.Value)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return configErrorf(path, "expected a list")
+		}
+		for i, value := range node.Content {
+			if err := checkSchema(value, t.Elem(), appendPath(path, fmt.Sprintf("[%d]", i))); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if node.Tag != "!!str" {
+			return configErrorf(path, "expected a string")
+		}
+	case reflect.Bool:
+		if node.Tag != "!!bool" {
+			return configErrorf(path, "expected true or false")
+		}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		if node.Tag != "!!int" {
+			return configErrorf(path, "expected a whole number")
+		}
+	case reflect.Float32, reflect.Float64:
+		if node.Tag != "!!int" && node.Tag != "!!float" {
+			return configErrorf(path, "expected a number")
+		}
+	}
+	return nil
+}
+
+// checkServerSchema checks a server against the config type of its
+// transport
+func checkServerSchema(node *yaml.Node, path []string) error {
+	if node.Kind != yaml.MappingNode {
+		return configErrorf(path, "expected an object")
+	}
+
+	var transport, url string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		switch node.Content[i].Value {
+		case "transport":
+			transport = node.Content[i+1].Value
+		case "url":
+			url = node.Content[i+1].Value
+		}
+	}
+	transport, err := serverTransport(transport, url)
+	if err != nil {
+		return &configError{path: path, err: err}
+	}
+
+	var config ServerConfig
+	switch transport {
+	case transportSSE:
+		config = SSEServerConfig{}
+	case transportHTTP:
+		config = StreamableHTTPServerConfig{}
+	default:
+		config = STDIOServerConfig{}
+	}
+	return checkSchema(node, reflect.TypeOf(config), path)
+}
+
+// schemaFields maps the JSON names of the fields of a struct to their
+// types, with the fields of embedded structs included
+func schemaFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			for name, fieldType := range schemaFields(field.Type) {
+				fields[name] = fieldType
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// findNode returns the node of the deepest key of a path that is in the
+// document. Keys of objects point at the key rather than at its value.
+func findNode(root *yaml.Node, path []string) *yaml.Node {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind == 0 {
+		return nil
+	}
+
+	found := node
+	for _, key := range path {
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+		switch node.Kind {
+		case yaml.MappingNode:
+			var next *yaml.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					found, next = node.Content[i], node.Content[i+1]
+					break
+				}
+			}
+			if next == nil {
+				return found
+			}
+			node = next
+		case yaml.SequenceNode:
+			index, ok := pathIndex(key)
+			if !ok || index >= len(node.Content) {
+				return found
+			}
+			node = node.Content[index]
+			found = node
+		default:
+			return found
+		}
+	}
+	return found
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeConfigPositions(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		data     string
+		project  bool
+		wantErr  string
+	}{
+		{
+			name:     "json valid",
+			filename: "config.json",
+			data: `{
+  "model": "openai:gpt-4o",
+  "mcpServers": {
+    "fs": {"command": "npx", "args": ["server"]}
+  }
+}`,
+		},
+		{
+			name:     "json syntax",
+			filename: "config.json",
+			data: `{
+  "model": "openai:gpt-4o",
+  "messageWindow" 10
+}`,
+			wantErr: "config.json:3:19:",
+		},
+		{
+			name:     "json unknown key",
+			filename: "config.json",
+			data: `{
+  "mcpServers": {
+    "fs": {
+      "comand": "npx"
+    }
+  }
+}`,
+			wantErr: "config.json:4:7: mcpServers.fs.comand: unknown key",
+		},
+		{
+			name:     "json wrong type",
+			filename: "config.json",
+			data: `{
+  "model": "openai:gpt-4o",
+  "messageWindow": "10"
+}`,
+			wantErr: "config.json:3:3: messageWindow: expected a whole number",
+		},
+		{
+			name:     "json list item",
+			filename: "config.json",
+			data: `{
+  "roots": [
+    "/a",
+    3
+  ]
+}`,
+			wantErr: "config.json:4:5: roots[1]: expected a string",
+		},
+		{
+			name:     "json invalid value",
+			filename: "config.json",
+			data: `{
+
+  "model": "gpt-4o"
+}`,
+			wantErr: "config.json:3:3: model:",
+		},
+		{
+			name:     "json project provider",
+			filename: ".mcphost.json",
+			data: `{
+  "providers": {
+    "openai": {
+      "baseUrl": "https://example.com/v1"
+    }
+  }
+}`,
+			project: true,
+			wantErr: ".mcphost.json:4:7: providers.openai.baseUrl: can't be set by a project config",
+		},
+		{
+			name:     "json user provider",
+			filename: ".mcp.json",
+			data: `{
+  "providers": {
+    "openai": {
+      "baseUrl": "https://example.com/v1"
+    }
+  }
+}`,
+		},
+		{
+			name:     "yaml valid",
+			filename: "config.yaml",
+			data: `model: openai:gpt-4o
+mcpServers:
+  fs:
+    command: npx
+    args: [server]
+`,
+		},
+		{
+			name:     "yaml syntax",
+			filename: "config.yaml",
+			data: `model: openai:gpt-4o
+mcpServers:
+  fs: [
+`,
+			wantErr: "error parsing config file config.yaml:",
+		},
+		{
+			name:     "yaml unknown key",
+			filename: "config.yaml",
+			data: `model: openai:gpt-4o
+mcpServers:
+  fs:
+    command: npx
+    enviroment: {}
+`,
+			wantErr: "config.yaml:5:5: mcpServers.fs.enviroment: unknown key",
+		},
+		{
+			name:     "yaml wrong type",
+			filename: "config.yml",
+			data: `generation:
+  temperature: warm
+`,
+			wantErr: "config.yml:2:3: generation.temperature: expected a number",
+		},
+		{
+			name:     "yaml project provider",
+			filename: ".mcphost.yaml",
+			data: `providers:
+  anthropic:
+    apiKey: ${ANTHROPIC_API_KEY}
+`,
+			project: true,
+			wantErr: ".mcphost.yaml:3:5: providers.anthropic.apiKey: can't be set by a project config",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var config MCPConfig
+			err := decodeConfig(test.filename, []byte(test.data), &config, test.project)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("decodeConfig() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("decodeConfig() error = nil, want %q", test.wantErr)
+			}
+			if !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("decodeConfig() error = %q, want it to contain %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const secretCommandTimeout = 30 * time.Second
+
+// SecretsConfig lists where ${VAR} references in the server configs are
+// looked up when VAR is not in the environment
+type SecretsConfig struct {
+	// Dotenv files are read in order, relative paths are relative to the
+	// config file. The first file setting a variable wins.
+	Dotenv []string `json:"dotenv,omitempty"`
+
+	// Commands map variables to shell commands printing their value, for
+	// example "pass show github/token"
+	Commands map[string]string `json:"commands,omitempty"`
+}
+
+// secretResolver expands the ${VAR} and ${VAR:-default} references in the
+// config of a server when the server is started, so the config itself can
+// be shared without the secrets
+type secretResolver struct {
+	config SecretsConfig
+
+	mu       sync.Mutex
+	dotenv   map[string]string
+	commands map[string]string
+}
+
+func newSecretResolver(config *MCPConfig) *secretResolver {
+	resolver := &secretResolver{commands: make(map[string]string)}
+	if config.Secrets != nil {
+		resolver.config = *config.Secrets
+	}
+	return resolver
+}
+
+// secrets returns the resolver for the references in the config, which
+// runs each secret command once
+func (c *MCPConfig) secrets() *secretResolver {
+	if c.resolver == nil {
+		c.resolver = newSecretResolver(c)
+	}
+	return c.resolver
+}
+
+// lookup finds a variable in the environment, the dotenv files or the
+// output of its command, in this order
+func (r *secretResolver) lookup(name string) (string, bool, error) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.dotenv == nil {
+		r.dotenv = make(map[string]string)
+		for _, path := range r.config.Dotenv {
+			path, err := absRoot(path)
+			if err != nil {
+				return "", false, err
+			}
+			values, err := readDotenv(path)
+			if err != nil {
+				return "", false, err
+			}
+			for key, value := range values {
+				if _, ok := r.dotenv[key]; !ok {
+					r.dotenv[key] = value
+				}
+			}
+		}
+	}
+	if value, ok := r.dotenv[name]; ok {
+		return value, true, nil
+	}
+
+	if value, ok := r.commands[name]; ok {
+		return value, true, nil
+	}
+	command, ok := r.config.Commands[name]
+	if !ok {
+		return "", false, nil
+	}
+	value, err := runSecretCommand(command)
+	if err != nil {
+		return "", false, fmt.Errorf("error getting %s: %w", name, err)
+	}
+	r.commands[name] = value
+	return value, true, nil
+}
+
+func (r *secretResolver) expand(s string) (string, error) {
+	return expandVars(s, r.lookup)
+}
+
+// resolveServer returns the config of a server with all references
+// expanded
+func (r *secretResolver) resolveServer(server ServerConfigWrapper) (ServerConfigWrapper, error) {
+	var err error
+	expand := func(s string) string {
+		if err != nil {
+			return s
+		}
+		var expanded string
+		expanded, err = r.expand(s)
+		return expanded
+	}
+	expandAll := func(values []string) []string {
+		if values == nil {
+			return nil
+		}
+		expanded := make([]string, len(values))
+		for i, value := range values {
+			expanded[i] = expand(value)
+		}
+		return expanded
+	}
+	expandOAuth := func(options *OAuthOptions) *OAuthOptions {
+		if options == nil {
+			return nil
+		}
+		expanded := *options
+		expanded.ClientID = expand(options.ClientID)
+		expanded.ClientSecret = expand(options.ClientSecret)
+		return &expanded
+	}
+
+	switch config := server.Config.(type) {
+	case STDIOServerConfig:
+		config.Command = expand(config.Command)
+		config.Args = expandAll(config.Args)
+		if config.Env != nil {
+			env := make(map[string]string, len(config.Env))
+			for key, value := range config.Env {
+				env[key] = expand(value)
+			}
+			config.Env = env
+		}
+		server.Config = config
+	case SSEServerConfig:
+		config.Url = expand(config.Url)
+		config.Headers = expandAll(config.Headers)
+		config.OAuth = expandOAuth(config.OAuth)
+		server.Config = config
+	case StreamableHTTPServerConfig:
+		config.Url = expand(config.Url)
+		config.Headers = expandAll(config.Headers)
+		config.OAuth = expandOAuth(config.OAuth)
+		server.Config = config
+	}
+	return server, err
+}
+
+// expandVars replaces ${VAR} with the value of VAR, and ${VAR:-default}
+// with the default when VAR is unset or empty. $$ stands for a $, and a $
+// that isn't followed by { is kept as it is.
+func expandVars(s string, lookup func(name string) (string, bool, error)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+			continue
+		case '{':
+		default:
+			b.WriteByte('$')
+			continue
+		}
+
+		end := strings.IndexByte(s[i+2:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated reference in %q", s)
+		}
+		reference := s[i+2 : i+2+end]
+		i += 2 + end
+
+		name, defaultValue, hasDefault := strings.Cut(reference, ":-")
+		if !validVarName(name) {
+			return "", fmt.Errorf("invalid variable name %q", name)
+		}
+		value, ok, err := lookup(name)
+		if err != nil {
+			return "", err
+		}
+		if hasDefault && value == "" {
+			value, ok = defaultValue, true
+		}
+		if !ok {
+			return "", fmt.Errorf("variable %s is not set", name)
+		}
+		b.WriteString(value)
+	}
+	return b.String(), nil
+}
+
+func validVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		if c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') ||
+			(i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// checkVars checks the syntax of the references in s
+func checkVars(s string) error {
+	_, err := expandVars(s, func(string) (string, bool, error) {
+		return "", true, nil
+	})
+	return err
+}
+
+// readDotenv reads KEY=value lines. Values may be quoted, and lines may
+// start with export.
+func readDotenv(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading dotenv file: %w", err)
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !validVarName(key) {
+			return nil, fmt.Errorf("%s:%d: expected KEY=value", path, lineNumber)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			value = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			// Unquoted values can end with a comment
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading dotenv file: %w", err)
+	}
+	return values, nil
+}
+
+// runSecretCommand runs a command with the shell and returns its output
+// without the trailing newline
+func runSecretCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("command %q failed: %w", command, err)
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandVars(t *testing.T) {
+	values := map[string]string{
+		"TOKEN": "secret",
+		"EMPTY": "",
+		"HOST":  "example.com",
+	}
+	lookup := func(name string) (string, bool, error) {
+		if name == "BROKEN" {
+			return "", false, errors.New("command failed")
+		}
+		value, ok := values[name]
+		return value, ok, nil
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{name: "no references", input: "plain text", want: "plain text"},
+		{name: "reference", input: "Bearer ${TOKEN}", want: "Bearer secret"},
+		{name: "several", input: "https://${HOST}/${TOKEN}", want: "https://example.com/secret"},
+		{name: "default unused", input: "${HOST:-localhost}", want: "example.com"},
+		{name: "default unset", input: "${PORT:-8080}", want: "8080"},
+		{name: "default empty", input: "${EMPTY:-fallback}", want: "fallback"},
+		{name: "empty default", input: "a${PORT:-}b", want: "ab"},
+		{name: "empty", input: "a${EMPTY}b", want: "ab"},
+		{name: "escaped", input: "cost $$5 for $${TOKEN}", want: "cost $5 for ${TOKEN}"},
+		{name: "bare dollar", input: "$TOKEN and $ and trailing $", want: "$TOKEN and $ and trailing $"},
+		{name: "unset", input: "${PORT}", wantErr: "variable PORT is not set"},
+		{name: "unterminated", input: "${TOKEN", wantErr: "unterminated reference"},
+		{name: "invalid name", input: "${1TOKEN}", wantErr: `invalid variable name "1TOKEN"`},
+		{name: "no name", input: "${:-x}", wantErr: `invalid variable name ""`},
+		{name: "lookup error", input: "${BROKEN}", wantErr: "command failed"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := expandVars(test.input, lookup)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("expandVars(%q) = %q, %v, want error %q", test.input, got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expandVars(%q) error = %v", test.input, err)
+			}
+			if got != test.want {
+				t.Errorf("expandVars(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadDotenv(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "values",
+			data: `# tokens
+TOKEN=secret
+export HOST = example.com
+
+EMPTY=
+`,
+			want: map[string]string{"TOKEN": "secret", "HOST": "example.com", "EMPTY": ""},
+		},
+		{
+			name: "quoted",
+			data: `DOUBLE="a \"b\"\nc # not a comment"
+SINGLE='raw \n value'
+`,
+			want: map[string]string{"DOUBLE": "a \"b\"\nc # not a comment", "SINGLE": `raw \n value`},
+		},
+		{
+			name: "comment",
+			data: "TOKEN=secret # the token\nHASH=a#b\n",
+			want: map[string]string{"TOKEN": "secret", "HASH": "a#b"},
+		},
+		{
+			name: "later wins",
+			data: "TOKEN=one\nTOKEN=two\n",
+			want: map[string]string{"TOKEN": "two"},
+		},
+		{
+			name:    "no value",
+			data:    "TOKEN=secret\nHOST\n",
+			wantErr: ".env:2: expected KEY=value",
+		},
+		{
+			name:    "invalid key",
+			data:    "MY-TOKEN=secret\n",
+			wantErr: ".env:1: expected KEY=value",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".env")
+			if err := os.WriteFile(path, []byte(test.data), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readDotenv(path)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("readDotenv() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readDotenv() error = %v", err)
+			}
+			if len(got) != len(test.want) {
+				t.Errorf("readDotenv() = %q, want %q", got, test.want)
+			}
+			for key, want := range test.want {
+				if got[key] != want {
+					t.Errorf("%s = %q, want %q", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestSecretResolverLookup(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.env")
+	second := filepath.Join(dir, "second.env")
+	if err := os.WriteFile(first, []byte("DOTENV_TOKEN=first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("DOTENV_TOKEN=second\nDOTENV_HOST=example.com\nENV_TOKEN=dotenv\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	counter := filepath.Join(dir, "runs")
+	t.Setenv("ENV_TOKEN", "env")
+
+	resolver := newSecretResolver(&MCPConfig{Secrets: &SecretsConfig{
+		Dotenv: []string{first, second},
+		Commands: map[string]string{
+			"COMMAND_TOKEN": "echo run >> " + counter + " && echo command",
+			"FAILING_TOKEN": "exit 3",
+		},
+	}})
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		// The environment wins over the dotenv files
+		{name: "environment", input: "${ENV_TOKEN}", want: "env"},
+		// The first dotenv file setting a variable wins
+		{name: "dotenv", input: "${DOTENV_TOKEN}@${DOTENV_HOST}", want: "first@example.com"},
+		{name: "command", input: "${COMMAND_TOKEN}", want: "command"},
+		{name: "command again", input: "${COMMAND_TOKEN}", want: "command"},
+		{name: "failing command", input: "${FAILING_TOKEN}", wantErr: "error getting FAILING_TOKEN"},
+		{name: "unset", input: "${MISSING_TOKEN}", wantErr: "variable MISSING_TOKEN is not set"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := resolver.expand(test.input)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("expand(%q) = %q, %v, want error %q", test.input, got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expand(%q) error = %v", test.input, err)
+			}
+			if got != test.want {
+				t.Errorf("expand(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+
+	// Each command runs once
+	runs, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := strings.Count(string(runs), "run"); count != 1 {
+		t.Errorf("command ran %d times, want 1", count)
+	}
+}
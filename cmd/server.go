@@ -5,32 +5,231 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/charmbracelet/log"
 	mcpclient "github.com/mark3labs/mcp-go/client"
-	"github.com/mark3labs/mcp-go/mcp"
 
+	"github.com/mark3labs/mcphost/pkg/agent"
 	"github.com/mark3labs/mcphost/pkg/history"
 	"github.com/mark3labs/mcphost/pkg/llm"
+	"github.com/mark3labs/mcphost/pkg/session"
 )
 
 type Request struct {
 	Prompt string
+
+	// Stream asks for the progress of the tool loop as server-sent events
+	// instead of a single Response
+	Stream bool
 }
 
 type Response struct {
 	Message string
 }
 
+// Events sent to clients of a streamed chat, named after their SSE event type
+
+// TextEvent is a piece of the assistant's text ("text")
+type TextEvent struct {
+	Text string `json:"text"`
+}
+
+// ToolUseEvent is sent when the model calls a tool ("tool_use")
+type ToolUseEvent struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// ToolResultEvent carries the result of a tool call ("tool_result")
+type ToolResultEvent struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	IsError bool   `json:"is_error,omitempty"`
+}
+
+// UsageEvent reports the token usage of one model call ("usage")
+type UsageEvent struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// FinalEvent ends a successful stream with the final answer ("final")
+type FinalEvent struct {
+	Message string `json:"message"`
+}
+
+// ErrorEvent ends a failed stream ("error")
+type ErrorEvent struct {
+	Error string `json:"error"`
+}
+
+// SessionInfo describes a conversation held by the server
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastActive time.Time `json:"last_active"`
+	Messages   int       `json:"messages"`
+}
+
+// SessionDetail is a session together with its conversation history
+type SessionDetail struct {
+	SessionInfo
+	History []history.HistoryMessage `json:"history"`
+}
+
+// serverSession is a conversation owned by one client of the server. mu is
+// held for the whole of a chat turn so requests to a session run one at a time.
+type serverSession struct {
+	mu         sync.Mutex
+	session    *session.Session
+	lastActive time.Time
+}
+
+// info must be called with mu held
+func (s *serverSession) info(lastActive time.Time) SessionInfo {
+	return SessionInfo{
+		ID:         s.session.ID,
+		CreatedAt:  s.session.CreatedAt,
+		LastActive: lastActive,
+		Messages:   len(s.session.Messages),
+	}
+}
+
+// sessionManager keeps the sessions of the server and expires idle ones
+type sessionManager struct {
+	mu       sync.Mutex
+	sessions map[string]*serverSession
+	ttl      time.Duration
+}
+
+func newSessionManager(ttl time.Duration) *sessionManager {
+	return &sessionManager{
+		sessions: make(map[string]*serverSession),
+		ttl:      ttl,
+	}
+}
+
+func (m *sessionManager) create() *serverSession {
+	sess := &serverSession{
+		session:    session.New(modelFlag),
+		lastActive: time.Now(),
+	}
+
+	m.mu.Lock()
+	m.sessions[sess.session.ID] = sess
+	m.mu.Unlock()
+
+	return sess
+}
+
+// get returns the session with the given id and marks it as active
+func (m *sessionManager) get(id string) (*serverSession, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sess, ok := m.sessions[id]
+	if ok {
+		sess.lastActive = time.Now()
+	}
+	return sess, ok
+}
+
+func (m *sessionManager) touch(sess *serverSession) {
+	m.mu.Lock()
+	sess.lastActive = time.Now()
+	m.mu.Unlock()
+}
+
+func (m *sessionManager) lastActive(sess *serverSession) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sess.lastActive
+}
+
+func (m *sessionManager) delete(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.sessions[id]
+	delete(m.sessions, id)
+	return ok
+}
+
+func (m *sessionManager) list() []*serverSession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sessions := make([]*serverSession, 0, len(m.sessions))
+	for _, sess := range m.sessions {
+		sessions = append(sessions, sess)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].session.CreatedAt.Before(sessions[j].session.CreatedAt)
+	})
+	return sessions
+}
+
+// expire removes sessions that have been idle for longer than the ttl.
+// Sessions in the middle of a chat turn are never removed.
+func (m *sessionManager) expire() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, sess := range m.sessions {
+		if time.Since(sess.lastActive) < m.ttl {
+			continue
+		}
+		if !sess.mu.TryLock() {
+			continue
+		}
+		delete(m.sessions, id)
+		sess.mu.Unlock()
+		log.Info("Session expired", "id", id)
+	}
+}
+
+// run expires idle sessions until ctx is done
+func (m *sessionManager) run(ctx context.Context) {
+	if m.ttl <= 0 {
+		return
+	}
+
+	interval := m.ttl / 2
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.expire()
+		}
+	}
+}
+
 func runServer(ctx context.Context,
 	provider llm.Provider,
 	mcpClients map[string]mcpclient.MCPClient,
-	tools []llm.Tool,
+	approver *toolApprover,
+	tools *toolSet,
 ) error {
-	messages := make([]history.HistoryMessage, 0)
+	sessions := newSessionManager(sessionTTL)
+	go sessions.run(ctx)
+
+	// chat runs one turn of a conversation and writes the final answer
+	chat := func(w http.ResponseWriter, r *http.Request, sess *serverSession) {
+		ctx, cancel := requestContext(ctx, r)
+		defer cancel()
 
-	http.HandleFunc("/api/v1/chat", func(w http.ResponseWriter, r *http.Request) {
 		request := Request{}
 
 		err := json.NewDecoder(r.Body).Decode(&request)
@@ -39,180 +238,279 @@ func runServer(ctx context.Context,
 			return
 		}
 
-		message, err := runPromptNonInteractive(ctx, provider, mcpClients, tools, request.Prompt, &messages)
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		defer sessions.touch(sess)
+
+		messages := &sess.session.Messages
+
+		if request.Stream {
+			streamChat(ctx, w, provider, mcpClients, approver, tools.all(), request.Prompt, messages)
+			return
+		}
+
+		message, err := runPromptNonInteractive(ctx, provider, mcpClients, approver, tools.all(), request.Prompt, messages, promptHooks{})
 		if err != nil {
+			log.Error("Error running prompt", "session", sess.session.ID, "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		if len(message) > 0 {
-			messages = pruneMessages(messages)
-		}
 		json.NewEncoder(w).Encode(Response{Message: message})
-	})
-	return http.ListenAndServe(":6002", nil)
-}
-
-func runPromptNonInteractive(
-	ctx context.Context,
-	provider llm.Provider,
-	mcpClients map[string]mcpclient.MCPClient,
-	tools []llm.Tool,
-	prompt string,
-	messages *[]history.HistoryMessage,
-) (string, error) {
-	var message llm.Message
-	var err error
-
-	// Convert MessageParam to llm.Message for provider
-	// Messages already implement llm.Message interface
-	llmMessages := make([]llm.Message, len(*messages))
-	for i := range *messages {
-		llmMessages[i] = &(*messages)[i]
 	}
 
-	message, err = provider.CreateMessage(
-		ctx,
-		prompt,
-		llmMessages,
-		tools,
-	)
+	// The original endpoint keeps a single conversation shared by all callers
+	shared := &serverSession{session: session.New(modelFlag)}
+	http.HandleFunc("POST /api/v1/chat", func(w http.ResponseWriter, r *http.Request) {
+		chat(w, r, shared)
+	})
 
-	if err != nil {
-		return "", err
-	}
+	http.HandleFunc("POST /api/v1/sessions", func(w http.ResponseWriter, r *http.Request) {
+		sess := sessions.create()
+		log.Info("Session created", "id", sess.session.ID)
 
-	var messageContent []history.ContentBlock
+		sess.mu.Lock()
+		info := sess.info(sessions.lastActive(sess))
+		sess.mu.Unlock()
 
-	if message.GetContent() != "" {
-		return message.GetContent(), nil
-	}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(info)
+	})
 
-	toolResults := []history.ContentBlock{}
-	messageContent = []history.ContentBlock{}
+	http.HandleFunc("GET /api/v1/sessions", func(w http.ResponseWriter, r *http.Request) {
+		infos := make([]SessionInfo, 0)
+		for _, sess := range sessions.list() {
+			sess.mu.Lock()
+			infos = append(infos, sess.info(sessions.lastActive(sess)))
+			sess.mu.Unlock()
+		}
 
-	// Add text content
-	if message.GetContent() != "" {
-		messageContent = append(messageContent, history.ContentBlock{
-			Type: "text",
-			Text: message.GetContent(),
-		})
-	}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(infos)
+	})
 
-	// Handle tool calls
-	for _, toolCall := range message.GetToolCalls() {
-		input, _ := json.Marshal(toolCall.GetArguments())
-		messageContent = append(messageContent, history.ContentBlock{
-			Type:  "tool_use",
-			ID:    toolCall.GetID(),
-			Name:  toolCall.GetName(),
-			Input: input,
-		})
+	http.HandleFunc("GET /api/v1/sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		sess, ok := sessions.get(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
 
-		// Log usage statistics if available
-		inputTokens, outputTokens := message.GetUsage()
-		if inputTokens > 0 || outputTokens > 0 {
-			log.Info("Usage statistics",
-				"input_tokens", inputTokens,
-				"output_tokens", outputTokens,
-				"total_tokens", inputTokens+outputTokens)
+		sess.mu.Lock()
+		detail := SessionDetail{
+			SessionInfo: sess.info(sessions.lastActive(sess)),
+			History:     append([]history.HistoryMessage(nil), sess.session.Messages...),
 		}
+		sess.mu.Unlock()
 
-		parts := strings.Split(toolCall.GetName(), "__")
-		if len(parts) != 2 {
-			fmt.Printf(
-				"Error: Invalid tool name format: %s\n",
-				toolCall.GetName(),
-			)
-			continue
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(detail)
+	})
+
+	http.HandleFunc("DELETE /api/v1/sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !sessions.delete(r.PathValue("id")) {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
 		}
+		log.Info("Session deleted", "id", r.PathValue("id"))
+		w.WriteHeader(http.StatusNoContent)
+	})
 
-		serverName, toolName := parts[0], parts[1]
-		mcpClient, ok := mcpClients[serverName]
+	http.HandleFunc("POST /api/v1/sessions/{id}/chat", func(w http.ResponseWriter, r *http.Request) {
+		sess, ok := sessions.get(r.PathValue("id"))
 		if !ok {
-			fmt.Printf("Error: Server not found: %s\n", serverName)
-			continue
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
 		}
+		chat(w, r, sess)
+	})
 
-		var toolArgs map[string]interface{}
-		if err := json.Unmarshal(input, &toolArgs); err != nil {
-			fmt.Printf("Error parsing tool arguments: %v\n", err)
-			continue
-		}
+	http.HandleFunc("POST /v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		handleChatCompletions(ctx, w, r, provider, mcpClients, approver, tools.all())
+	})
+	http.HandleFunc("GET /v1/models", handleModels)
 
-		var toolResultPtr *mcp.CallToolResult
-		req := mcp.CallToolRequest{}
-		req.Params.Name = toolName
-		req.Params.Arguments = toolArgs
-		toolResultPtr, err = mcpClient.CallTool(
-			context.Background(),
-			req,
-		)
+	return http.ListenAndServe(":6002", nil)
+}
 
-		if err != nil {
-			errMsg := fmt.Sprintf(
-				"Error calling tool %s: %v",
-				toolName,
-				err,
-			)
-			fmt.Printf("\n%s\n", errorStyle.Render(errMsg))
-
-			// Add error message as tool result
-			toolResults = append(toolResults, history.ContentBlock{
-				Type:      "tool_result",
-				ToolUseID: toolCall.GetID(),
-				Content: []history.ContentBlock{{
-					Type: "text",
-					Text: errMsg,
-				}},
-			})
-			continue
-		}
+// requestContext returns the context to serve a request with, which is done
+// once the client goes away or the server stops
+func requestContext(ctx context.Context, r *http.Request) (context.Context, context.CancelFunc) {
+	requestCtx, cancel := context.WithCancel(r.Context())
+	stop := context.AfterFunc(ctx, cancel)
+	return requestCtx, func() {
+		stop()
+		cancel()
+	}
+}
 
-		toolResult := *toolResultPtr
+// eventStream writes server-sent events to a client
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
 
-		if toolResult.Content != nil {
-			log.Debug("raw tool result content", "content", toolResult.Content)
+// newEventStream sends the event stream headers. It fails if the connection
+// can't be flushed.
+func newEventStream(w http.ResponseWriter) (*eventStream, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
 
-			// Create the tool result block
-			resultBlock := history.ContentBlock{
-				Type:      "tool_result",
-				ToolUseID: toolCall.GetID(),
-				Content:   toolResult.Content,
-			}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
 
-			// Extract text content
-			var resultText string
-			// Handle array content directly since we know it's []interface{}
-			for _, item := range toolResult.Content {
-				if contentMap, ok := item.(mcp.TextContent); ok {
-					resultText += fmt.Sprintf("%v ", contentMap.Text)
-				}
-			}
+	return &eventStream{w: w, flusher: flusher}, true
+}
 
-			resultBlock.Text = strings.TrimSpace(resultText)
-			log.Debug("created tool result block",
-				"block", resultBlock,
-				"tool_id", toolCall.GetID())
+// send writes data as JSON. The event line is left out if event is empty.
+func (s *eventStream) send(event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Error("Error encoding event", "event", event, "error", err)
+		return
+	}
+	if event != "" {
+		fmt.Fprintf(s.w, "event: %s\n", event)
+	}
+	fmt.Fprintf(s.w, "data: %s\n\n", payload)
+	s.flusher.Flush()
+}
 
-			toolResults = append(toolResults, resultBlock)
-		}
+// streamChat runs one turn of a conversation and reports every step of it
+// as a server-sent event
+func streamChat(
+	ctx context.Context,
+	w http.ResponseWriter,
+	provider llm.Provider,
+	mcpClients map[string]mcpclient.MCPClient,
+	approver *toolApprover,
+	tools []llm.Tool,
+	prompt string,
+	messages *[]history.HistoryMessage,
+) {
+	stream, ok := newEventStream(w)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
 	}
 
-	*messages = append(*messages, history.HistoryMessage{
-		Role:    message.GetRole(),
-		Content: messageContent,
+	hooks := promptHooks{
+		onText: func(text string) {
+			stream.send("text", TextEvent{Text: text})
+		},
+		onUsage: func(inputTokens, outputTokens int) {
+			if inputTokens > 0 || outputTokens > 0 {
+				stream.send("usage", UsageEvent{
+					InputTokens:  inputTokens,
+					OutputTokens: outputTokens,
+				})
+			}
+		},
+		onToolUse: func(id, name string, arguments map[string]interface{}) {
+			stream.send("tool_use", ToolUseEvent{
+				ID:        id,
+				Name:      name,
+				Arguments: arguments,
+			})
+		},
+		onToolResult: func(id, name, result string, isError bool) {
+			stream.send("tool_result", ToolResultEvent{
+				ID:      id,
+				Name:    name,
+				Content: result,
+				IsError: isError,
+			})
+		},
+	}
+
+	message, err := runPromptNonInteractive(ctx, provider, mcpClients, approver, tools, prompt, messages, hooks)
+	if err != nil {
+		log.Error("Error running prompt", "error", err)
+		stream.send("error", ErrorEvent{Error: err.Error()})
+		return
+	}
+
+	stream.send("final", FinalEvent{Message: message})
+}
+
+// promptHooks lets callers of runPromptNonInteractive follow its progress.
+// Hooks that are not set are skipped.
+type promptHooks struct {
+	// onText receives the assistant's text, in pieces if the provider streams
+	onText func(text string)
+
+	// onUsage receives the token usage of every model call
+	onUsage func(inputTokens, outputTokens int)
+
+	// onToolUse is called before a tool is run
+	onToolUse func(id, name string, arguments map[string]interface{})
+
+	// onToolResult receives the text of a tool's result
+	onToolResult func(id, name, result string, isError bool)
+}
+
+// runPromptNonInteractive runs a prompt without a terminal and returns the
+// model's final answer
+func runPromptNonInteractive(
+	ctx context.Context,
+	provider llm.Provider,
+	mcpClients map[string]mcpclient.MCPClient,
+	approver *toolApprover,
+	tools []llm.Tool,
+	prompt string,
+	messages *[]history.HistoryMessage,
+	hooks promptHooks,
+) (string, error) {
+	a := agent.New(agent.Options{
+		Provider: provider,
+		Clients:  mcpClients,
+		Tools:    func() []llm.Tool { return tools },
+		Executor: &hostExecutor{
+			config:     approver.config,
+			mcpClients: mcpClients,
+			approver:   approver,
+		},
+		Stream:  streamResponses && hooks.onText != nil,
+		History: *messages,
+
+		MaxSteps:            maxSteps,
+		MaxRepeatedCalls:    maxRepeatedCalls,
+		ContextTokens:       contextBudget.conversation,
+		MaxMessages:         messageWindow,
+		MaxToolResultTokens: contextBudget.toolResult,
 	})
 
-	if len(toolResults) > 0 {
-		for _, toolResult := range toolResults {
-			*messages = append(*messages, history.HistoryMessage{
-				Role:    "tool",
-				Content: []history.ContentBlock{toolResult},
-			})
+	message, err := a.Run(ctx, prompt, hooks.handle)
+	*messages = a.History()
+	return message, err
+}
+
+// handle passes the events of an agent to the hooks
+func (h promptHooks) handle(event agent.Event) {
+	switch e := event.(type) {
+	case agent.TextEvent:
+		if h.onText != nil {
+			h.onText(e.Text)
+		}
+	case agent.UsageEvent:
+		if h.onUsage != nil {
+			h.onUsage(e.InputTokens, e.OutputTokens)
+		}
+	case agent.ToolStartEvent:
+		if h.onToolUse != nil {
+			h.onToolUse(e.ID, e.Name, e.Arguments)
+		}
+	case agent.ToolEndEvent:
+		if h.onToolResult != nil {
+			h.onToolResult(e.ID, e.Name, e.Result, e.IsError)
+		}
+	case agent.ErrorEvent:
+		if e.Retry {
+			log.Warn("Model is overloaded, backing off...", "backoff", e.Backoff.String())
 		}
-		// Make another call to get Claude's response to the tool results
-		return runPromptNonInteractive(ctx, provider, mcpClients, tools, "", messages)
 	}
-	return "", nil
 }
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/mark3labs/mcphost/pkg/history"
+	"github.com/mark3labs/mcphost/pkg/llm"
+)
+
+// toolUseResponse returns a response that calls a tool with arguments
+func toolUseResponse(id, name string, arguments map[string]interface{}) llm.Message {
+	input, _ := json.Marshal(arguments)
+	return &history.HistoryMessage{
+		Role: "assistant",
+		Content: []history.ContentBlock{{
+			Type:  "tool_use",
+			ID:    id,
+			Name:  name,
+			Input: input,
+		}},
+	}
+}
+
+// newTestClient starts an MCP server with tools in the process and returns
+// a client connected to it
+func newTestClient(t *testing.T, tools ...server.ServerTool) *mcpclient.Client {
+	t.Helper()
+	client, err := startTestClient(tools...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func startTestClient(tools ...server.ServerTool) (*mcpclient.Client, error) {
+	mcpServer := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(false))
+	mcpServer.AddTools(tools...)
+
+	client, err := mcpclient.NewInProcessClient(mcpServer)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if err := client.Start(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+	request := mcp.InitializeRequest{}
+	request.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	if _, err := client.Initialize(ctx, request); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// echoTool answers with the text it is given
+var echoTool = server.ServerTool{
+	Tool: mcp.NewTool("say", mcp.WithString("text")),
+	Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(request.GetString("text", "")), nil
+	},
+}
+
+func TestSessionManagerExpire(t *testing.T) {
+	tests := []struct {
+		name string
+		idle time.Duration
+		busy bool
+		want bool
+	}{
+		{name: "active", idle: time.Second, want: true},
+		{name: "idle", idle: time.Hour, want: false},
+		// A session in the middle of a chat turn is kept however long the
+		// turn takes
+		{name: "busy", idle: time.Hour, busy: true, want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sessions := newSessionManager(time.Minute)
+			sess := sessions.create()
+			sess.lastActive = time.Now().Add(-test.idle)
+			if test.busy {
+				sess.mu.Lock()
+				defer sess.mu.Unlock()
+			}
+
+			sessions.expire()
+
+			if _, ok := sessions.get(sess.session.ID); ok != test.want {
+				t.Errorf("session kept = %v, want %v", ok, test.want)
+			}
+		})
+	}
+}
+
+// sseEvent is a server-sent event as the client reads it
+type sseEvent struct {
+	name string
+	data string
+}
+
+func readEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var event sseEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			events = append(events, event)
+			event = sseEvent{}
+		default:
+			t.Errorf("unexpected line %q", line)
+		}
+	}
+	return events
+}
+
+func TestStreamChat(t *testing.T) {
+	provider := &testProvider{respond: func(step int, messages []llm.Message) llm.Message {
+		if step == 1 {
+			return toolUseResponse("call_1", "echo__say", map[string]interface{}{"text": "hi"})
+		}
+		return textResponse("Done", 20, 3)
+	}}
+	mcpClients := map[string]mcpclient.MCPClient{"echo": newTestClient(t, echoTool)}
+	config := &MCPConfig{MCPServers: map[string]ServerConfigWrapper{
+		"echo": {Config: STDIOServerConfig{
+			Command:       "echo",
+			ServerOptions: ServerOptions{ToolPolicies: map[string]string{"say": policyAuto}},
+		}},
+	}}
+
+	var messages []history.HistoryMessage
+	recorder := httptest.NewRecorder()
+	streamChat(context.Background(), recorder, provider, mcpClients,
+		newToolApprover(config, false), nil, "Say hi", &messages)
+
+	if got := recorder.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+	want := []sseEvent{
+		{"tool_use", `{"id":"call_1","name":"echo__say","arguments":{"text":"hi"}}`},
+		{"tool_result", `{"id":"call_1","name":"echo__say","content":"hi"}`},
+		{"text", `{"text":"Done"}`},
+		{"usage", `{"input_tokens":20,"output_tokens":3}`},
+		{"final", `{"message":"Done"}`},
+	}
+	got := readEvents(t, recorder.Body.String())
+	if len(got) != len(want) {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	// The turn is kept in the history of the session
+	if lines := describeHistory(messages); len(lines) != 4 {
+		t.Errorf("history = %q, want the prompt, the call, its result and the answer", lines)
+	}
+}
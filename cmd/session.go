@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/mark3labs/mcphost/pkg/session"
+)
+
+// chatSession ties the conversation of the interactive loop to the session
+// it is saved as
+type chatSession struct {
+	store   *session.Store
+	current *session.Session
+}
+
+// openChatSession resumes the session requested by --resume or --continue,
+// or starts a new one
+func openChatSession() (*chatSession, error) {
+	dir := sessionsDir
+	if dir == "" {
+		var err error
+		dir, err = session.DefaultDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+	chat := &chatSession{store: session.NewStore(dir)}
+
+	switch {
+	case resumeSession != "":
+		sess, err := chat.store.Load(resumeSession)
+		if err != nil {
+			return nil, err
+		}
+		chat.current = sess
+	case continueSession:
+		sess, err := chat.store.Latest()
+		if errors.Is(err, session.ErrNotFound) {
+			log.Warn("No saved sessions to continue, starting a new one")
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		chat.current = sess
+	}
+
+	if chat.current != nil {
+		log.Info("Session resumed",
+			"id", chat.current.ID,
+			"messages", len(chat.current.Messages))
+	} else {
+		chat.current = session.New(modelFlag)
+	}
+
+	return chat, nil
+}
+
+// save writes the current session to disk. Sessions without any messages
+// are not written.
+func (c *chatSession) save() {
+	if len(c.current.Messages) == 0 {
+		return
+	}
+	if err := c.store.Save(c.current); err != nil {
+		log.Error("Failed to save session", "id", c.current.ID, "error", err)
+	}
+}
+
+func handleSaveCommand(chat *chatSession, args []string) {
+	sess := chat.current
+	if len(args) > 0 {
+		// Save as a copy so a bad id leaves the current session untouched
+		renamed := *chat.current
+		renamed.ID = args[0]
+		sess = &renamed
+	}
+	if err := chat.store.Save(sess); err != nil {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Error saving session: %v", err)))
+		return
+	}
+	chat.current = sess
+	fmt.Printf("\n%s\n\n", promptStyle.Render("Session saved: "+sess.ID))
+}
+
+func handleLoadCommand(chat *chatSession, args []string) {
+	if len(args) == 0 {
+		fmt.Printf("\n%s\n\n", errorStyle.Render("Usage: /load <id>"))
+		return
+	}
+
+	sess, err := chat.store.Load(args[0])
+	if err != nil {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Error loading session: %v", err)))
+		return
+	}
+	chat.current = sess
+
+	fmt.Printf("\n%s\n\n", promptStyle.Render(fmt.Sprintf(
+		"Session loaded: %s (%d messages)", sess.ID, len(sess.Messages))))
+}
+
+func handleSessionsCommand(chat *chatSession) {
+	if err := updateRenderer(); err != nil {
+		fmt.Printf(
+			"\n%s\n",
+			errorStyle.Render(fmt.Sprintf("Error updating renderer: %v", err)),
+		)
+		return
+	}
+
+	sessions, err := chat.store.List()
+	if err != nil {
+		fmt.Printf("\n%s\n", errorStyle.Render(fmt.Sprintf("Error listing sessions: %v", err)))
+		return
+	}
+
+	var markdown strings.Builder
+	markdown.WriteString("# Saved Sessions\n\n")
+	if len(sessions) == 0 {
+		markdown.WriteString("No saved sessions.\n")
+	}
+	for _, sess := range sessions {
+		current := ""
+		if sess.ID == chat.current.ID {
+			current = " *(current)*"
+		}
+		markdown.WriteString(fmt.Sprintf("- `%s`%s %s, %d messages: %s\n",
+			sess.ID,
+			current,
+			sess.UpdatedAt.Format("2006-01-02 15:04"),
+			len(sess.Messages),
+			sess.Title()))
+	}
+	markdown.WriteString(fmt.Sprintf("\nSessions are stored in `%s`\n", chat.store.Dir()))
+
+	rendered, err := renderer.Render(markdown.String())
+	if err != nil {
+		fmt.Printf(
+			"\n%s\n",
+			errorStyle.Render(fmt.Sprintf("Error rendering sessions: %v", err)),
+		)
+		return
+	}
+
+	fmt.Print(rendered)
+}
@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/mark3labs/mcphost/pkg/llm"
+)
+
+const (
+	providerAnthropic = "anthropic"
+	providerOpenAI    = "openai"
+	providerGoogle    = "google"
+	providerOllama    = "ollama"
+)
+
+var providerNames = []string{providerAnthropic, providerOpenAI, providerGoogle, providerOllama}
+
+// ProviderSettings are the settings of a model provider. Both may refer to
+// variables as ${VAR}.
+type ProviderSettings struct {
+	// APIKey is the key for the API of the provider, not used by ollama
+	APIKey string `json:"apiKey,omitempty"`
+
+	// BaseURL replaces the default URL of the API, not supported by google
+	BaseURL string `json:"baseUrl,omitempty"`
+}
+
+// validateSettings checks the settings of the config other than the
+// servers
+func validateSettings(config *MCPConfig) error {
+	if err := checkModel([]string{"model"}, config.Model); err != nil {
+		return err
+	}
+
+	for name, settings := range config.Providers {
+		if settings == nil {
+			continue
+		}
+		at := func(keys ...string) []string {
+			return append([]string{"providers", name}, keys...)
+		}
+		switch name {
+		case providerAnthropic, providerOpenAI:
+		case providerGoogle:
+			if settings.BaseURL != "" {
+				return configErrorf(at("baseUrl"), "%s doesn't support a base URL", name)
+			}
+		case providerOllama:
+			if settings.APIKey != "" {
+				return configErrorf(at("apiKey"), "%s doesn't use an API key", name)
+			}
+		default:
+			return configErrorf(
+				at(), "unknown provider %q, expected %s",
+				name, strings.Join(providerNames, ", "),
+			)
+		}
+		if err := checkVars(settings.APIKey); err != nil {
+			return &configError{path: at("apiKey"), err: err}
+		}
+		if err := checkVars(settings.BaseURL); err != nil {
+			return &configError{path: at("baseUrl"), err: err}
+		}
+	}
+
+	if config.SystemPrompt != "" && config.SystemPromptFile != "" {
+		return configErrorf([]string{"systemPromptFile"}, "set either systemPrompt or systemPromptFile")
+	}
+	if err := checkGeneration([]string{"generation"}, config.Generation); err != nil {
+		return err
+	}
+
+	if config.MessageWindow < 0 {
+		return configErrorf([]string{"messageWindow"}, "must not be negative")
+	}
+	if config.ContextLength < 0 {
+		return configErrorf([]string{"contextLength"}, "must not be negative")
+	}
+	if config.MaxToolResultTokens < 0 {
+		return configErrorf([]string{"maxToolResultTokens"}, "must not be negative")
+	}
+	if config.ToolConcurrency < 0 {
+		return configErrorf([]string{"toolConcurrency"}, "must not be negative")
+	}
+	if config.ToolTimeout < 0 {
+		return configErrorf([]string{"toolTimeout"}, "must not be negative")
+	}
+	if config.MaxSteps < 0 {
+		return configErrorf([]string{"maxSteps"}, "must not be negative")
+	}
+	if config.MaxRepeatedToolCalls < 0 {
+		return configErrorf([]string{"maxRepeatedToolCalls"}, "must not be negative")
+	}
+
+	for name, profile := range config.Profiles {
+		if profile == nil {
+			continue
+		}
+		at := func(keys ...string) []string {
+			return append([]string{"profiles", name}, keys...)
+		}
+		if err := checkModel(at("model"), profile.Model); err != nil {
+			return err
+		}
+		if profile.SystemPrompt != "" && profile.SystemPromptFile != "" {
+			return configErrorf(at("systemPromptFile"), "set either systemPrompt or systemPromptFile")
+		}
+		if err := checkGeneration(at("generation"), profile.Generation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkModel checks a provider:model setting
+func checkModel(path []string, model string) error {
+	if model == "" {
+		return nil
+	}
+	provider, name, ok := strings.Cut(model, ":")
+	if !ok || name == "" {
+		return configErrorf(path, "expected provider:model, got %q", model)
+	}
+	if !slices.Contains(providerNames, provider) {
+		return configErrorf(
+			path, "unknown provider %q, expected %s",
+			provider, strings.Join(providerNames, ", "),
+		)
+	}
+	return nil
+}
+
+func checkGeneration(path []string, params *llm.GenerationParams) error {
+	if params == nil {
+		return nil
+	}
+	if t := params.Temperature; t != nil && (*t < 0 || *t > 2) {
+		return configErrorf(appendPath(path, "temperature"), "must be between 0 and 2")
+	}
+	if p := params.TopP; p != nil && (*p < 0 || *p > 1) {
+		return configErrorf(appendPath(path, "topP"), "must be between 0 and 1")
+	}
+	if params.MaxTokens < 0 {
+		return configErrorf(appendPath(path, "maxTokens"), "must not be negative")
+	}
+	return nil
+}
+
+// settingsMu guards the settings useSettings sets and the config that a
+// profile switch replaces, which servers read from their own goroutines
+var settingsMu sync.RWMutex
+
+// useSettings makes mcphost run with the settings of the config. The flags
+// are already applied over them, and settings the config doesn't have go
+// back to their defaults.
+func useSettings(config *MCPConfig) error {
+	modelFlag = defaultModel
+	if config.Model != "" {
+		modelFlag = config.Model
+	}
+	messageWindow = defaultMessageWindow
+	if config.MessageWindow > 0 {
+		messageWindow = config.MessageWindow
+	}
+	contextLength = config.ContextLength
+	maxResultTokens = config.MaxToolResultTokens
+	toolConcurrency = defaultToolConcurrency
+	if config.ToolConcurrency > 0 {
+		toolConcurrency = config.ToolConcurrency
+	}
+	toolTimeout = defaultToolTimeout
+	if config.ToolTimeout > 0 {
+		toolTimeout = config.ToolTimeout
+	}
+	maxSteps = defaultMaxSteps
+	if config.MaxSteps > 0 {
+		maxSteps = config.MaxSteps
+	}
+	maxRepeatedCalls = defaultMaxRepeatedCalls
+	if config.MaxRepeatedToolCalls > 0 {
+		maxRepeatedCalls = config.MaxRepeatedToolCalls
+	}
+	generationParams = llm.GenerationParams{}
+	if config.Generation != nil {
+		generationParams = *config.Generation
+	}
+
+	anthropicAPIKey, anthropicBaseURL = "", ""
+	openaiAPIKey, openaiBaseURL = "", ""
+	googleAPIKey, ollamaBaseURL = "", ""
+
+	secrets := config.secrets()
+	for name, settings := range config.Providers {
+		if settings == nil {
+			continue
+		}
+		apiKey, err := secrets.expand(settings.APIKey)
+		if err != nil {
+			return fmt.Errorf("providers.%s.apiKey: %w", name, err)
+		}
+		baseURL, err := secrets.expand(settings.BaseURL)
+		if err != nil {
+			return fmt.Errorf("providers.%s.baseUrl: %w", name, err)
+		}
+
+		switch name {
+		case providerAnthropic:
+			anthropicAPIKey, anthropicBaseURL = apiKey, baseURL
+		case providerOpenAI:
+			openaiAPIKey, openaiBaseURL = apiKey, baseURL
+		case providerGoogle:
+			googleAPIKey = apiKey
+		case providerOllama:
+			ollamaBaseURL = baseURL
+		}
+	}
+	return nil
+}
+
+// contextBudget holds the tokens of the conversation and of a single tool
+// result that are sent to the model
+var contextBudget struct {
+	conversation int
+	toolResult   int
+}
+
+// useContextBudget works out the context budget for the model of a provider.
+// The context of the model, less the room for a response and the system
+// prompt, is left for the conversation.
+func useContextBudget(provider llm.Provider, systemPrompt string) {
+	length := modelContextLength(modelFlag)
+	response := generationParams.MaxTokens
+	if response == 0 {
+		response = defaultResponseTokens
+	}
+
+	// A long system prompt or response still leaves some room
+	conversation := length - response - llm.CountTokens(provider, systemPrompt)
+	if conversation < length/4 {
+		conversation = length / 4
+	}
+
+	toolResult := maxResultTokens
+	if toolResult == 0 {
+		toolResult = conversation / 4
+	}
+
+	contextBudget.conversation, contextBudget.toolResult = conversation, toolResult
+	log.Debug("Context budget",
+		"context_length", length,
+		"conversation_tokens", conversation,
+		"tool_result_tokens", toolResult)
+}
+
+// modelContextLength returns the number of tokens the context of a model
+// holds, which --context-length sets
+func modelContextLength(model string) int {
+	if contextLength > 0 {
+		return contextLength
+	}
+	return llm.ContextLength(model)
+}
+
+// systemPrompt returns the system prompt of the config, read from its file
+// if it has one
+func (c *MCPConfig) systemPrompt() (string, error) {
+	if c.SystemPromptFile != "" {
+		return loadSystemPrompt(c.SystemPromptFile)
+	}
+	return c.SystemPrompt, nil
+}
+
+// provider returns the settings of a provider, which are added to the
+// config if it has none
+func (c *MCPConfig) provider(name string) *ProviderSettings {
+	if c.Providers == nil {
+		c.Providers = make(map[string]*ProviderSettings)
+	}
+	if c.Providers[name] == nil {
+		c.Providers[name] = &ProviderSettings{}
+	}
+	return c.Providers[name]
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxResumeAttempts = 3
+	resumeDelay       = time.Second
+)
+
+// resumingTransport resumes the event streams of Streamable HTTP servers.
+// When the stream of a response breaks before the response arrived, the
+// events after the last one received are asked for with a GET request and
+// the Last-Event-ID header, as the MCP spec describes. The stream that is
+// listened to for server notifications resumes the same way when it is
+// opened again.
+type resumingTransport struct {
+	base http.RoundTripper
+
+	mu sync.Mutex
+	// sessionID is the MCP session of the last request. Event IDs are only
+	// meaningful within one session.
+	sessionID string
+	// lastEventID is the last event received on the listening stream of
+	// the session
+	lastEventID string
+}
+
+func (t *resumingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sessionID := req.Header.Get("Mcp-Session-Id")
+	t.mu.Lock()
+	if sessionID != t.sessionID {
+		t.sessionID = sessionID
+		t.lastEventID = ""
+	}
+	lastEventID := t.lastEventID
+	t.mu.Unlock()
+
+	listening := req.Method == http.MethodGet
+	if listening && req.Header.Get("Last-Event-ID") == "" {
+		if lastEventID != "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		return resp, nil
+	}
+
+	resp.Body = &resumableStream{
+		transport: t,
+		req:       req,
+		sessionID: sessionID,
+		listening: listening,
+		body:      resp.Body,
+	}
+	return resp, nil
+}
+
+// resumableStream passes on the events of a stream, and only whole events,
+// so that a stream that is resumed doesn't leave half an event behind
+type resumableStream struct {
+	transport *resumingTransport
+	req       *http.Request
+	sessionID string
+	listening bool
+	body      io.ReadCloser
+
+	line    []byte // the line being received
+	event   []byte // the lines of the event being received
+	eventID string
+	data    strings.Builder
+
+	ready  []byte // whole events that haven't been read yet
+	lastID string
+	done   bool // the response has arrived
+	err    error
+}
+
+func (s *resumableStream) Read(p []byte) (int, error) {
+	for len(s.ready) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		s.fill()
+	}
+	n := copy(p, s.ready)
+	s.ready = s.ready[n:]
+	return n, nil
+}
+
+func (s *resumableStream) Close() error {
+	return s.body.Close()
+}
+
+func (s *resumableStream) fill() {
+	buf := make([]byte, 4096)
+	n, err := s.body.Read(buf)
+	s.scan(buf[:n])
+	if err == nil {
+		return
+	}
+
+	if !s.listening && !s.done && s.lastID != "" && s.resume() {
+		return
+	}
+
+	// Pass on what is left, the reader may make sense of it
+	s.ready = append(s.ready, s.event...)
+	s.ready = append(s.ready, s.line...)
+	s.event, s.line = nil, nil
+	s.err = err
+}
+
+// scan splits the received bytes into lines and the lines into events
+func (s *resumableStream) scan(data []byte) {
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			s.line = append(s.line, data...)
+			return
+		}
+		s.line = append(s.line, data[:i+1]...)
+		data = data[i+1:]
+
+		line := strings.TrimRight(string(s.line), "\r\n")
+		s.event = append(s.event, s.line...)
+		s.line = nil
+
+		switch {
+		case line == "":
+			s.endEvent()
+		case strings.HasPrefix(line, "id:"):
+			s.eventID = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "data:"):
+			if s.data.Len() > 0 {
+				s.data.WriteByte('\n')
+			}
+			s.data.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		}
+	}
+}
+
+func (s *resumableStream) endEvent() {
+	s.ready = append(s.ready, s.event...)
+	s.event = nil
+
+	if s.eventID != "" {
+		s.lastID = s.eventID
+		if s.listening {
+			s.transport.mu.Lock()
+			if s.transport.sessionID == s.sessionID {
+				s.transport.lastEventID = s.eventID
+			}
+			s.transport.mu.Unlock()
+		}
+	}
+	if isJSONRPCResponse(s.data.String()) {
+		s.done = true
+	}
+	s.eventID = ""
+	s.data.Reset()
+}
+
+// resume continues a broken stream after the last event received. The
+// partly received event is dropped, the server sends it again.
+func (s *resumableStream) resume() bool {
+	for attempt := 1; attempt <= maxResumeAttempts; attempt++ {
+		select {
+		case <-s.req.Context().Done():
+			return false
+		case <-time.After(resumeDelay):
+		}
+
+		req, err := http.NewRequestWithContext(s.req.Context(), http.MethodGet, s.req.URL.String(), nil)
+		if err != nil {
+			return false
+		}
+		req.Header = s.req.Header.Clone()
+		req.Header.Del("Content-Type")
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set("Last-Event-ID", s.lastID)
+
+		resp, err := s.transport.base.RoundTrip(req)
+		if err != nil {
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			continue
+		}
+
+		_ = s.body.Close()
+		s.body = resp.Body
+		s.line, s.event = nil, nil
+		s.eventID = ""
+		s.data.Reset()
+		return true
+	}
+	return false
+}
+
+// isJSONRPCResponse reports whether the data of an event is the response
+// to a request, as opposed to a notification or a request of the server
+func isJSONRPCResponse(data string) bool {
+	var message struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(data), &message); err != nil {
+		return false
+	}
+	return len(message.ID) > 0 && message.Method == "" &&
+		(message.Result != nil || message.Error != nil)
+}
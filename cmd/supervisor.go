@@ -0,0 +1,648 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// States of a supervised server, as shown by /servers
+const (
+	serverNotStarted = "not started"
+	serverConnected  = "connected"
+	serverRestarting = "restarting"
+	serverFailed     = "failed"
+)
+
+const (
+	healthInterval = 15 * time.Second
+	pingTimeout    = 5 * time.Second
+
+	// failedRetryInterval is how long a failed server is left alone before
+	// it is tried again
+	failedRetryInterval = time.Minute
+)
+
+// supervisedClient keeps the connection to an MCP server alive. It pings the
+// server regularly and reconnects with backoff when the server stops
+// answering, its process exits, the connection drops or a request fails in
+// the transport. Servers that failed are tried again in the background.
+// Notification handlers are carried over to every new connection. Lazy
+// servers are started by the first request sent to them.
+type supervisedClient struct {
+	name    string
+	connect func(ctx context.Context) (*mcpclient.Client, error)
+
+	startMu   sync.Mutex
+	watchOnce sync.Once
+
+	mu         sync.Mutex
+	client     *mcpclient.Client
+	connCtx    context.Context
+	connCancel context.CancelCauseFunc
+	state      string
+	lastErr    error
+	since      time.Time
+	restarts   int
+	handlers   []func(mcp.JSONRPCNotification)
+	connected  []func()
+	closed     bool
+	done       chan struct{}
+}
+
+// serverStatus is a snapshot of the state of a supervised server
+type serverStatus struct {
+	State    string
+	Since    time.Time
+	Restarts int
+	LastErr  error
+}
+
+func newSupervisedClient(
+	name string,
+	connect func(ctx context.Context) (*mcpclient.Client, error),
+) *supervisedClient {
+	return &supervisedClient{
+		name:    name,
+		connect: connect,
+		state:   serverNotStarted,
+		done:    make(chan struct{}),
+	}
+}
+
+// start connects to the server for the first time and starts supervising
+// it. A server that fails to start is marked as failed.
+func (s *supervisedClient) start() error {
+	client, connCtx, connCancel, err := s.dial()
+
+	s.mu.Lock()
+	if err != nil {
+		s.state = serverFailed
+		s.since = time.Now()
+		s.lastErr = err
+	} else {
+		s.setClient(client, connCtx, connCancel)
+	}
+	s.mu.Unlock()
+
+	s.watchOnce.Do(func() {
+		go s.watch()
+	})
+	return err
+}
+
+// startLazy starts a lazy server on its first use
+func (s *supervisedClient) startLazy() {
+	s.startMu.Lock()
+	defer s.startMu.Unlock()
+	if s.status().State != serverNotStarted {
+		return
+	}
+
+	log.Info("Starting server on first use...", "name", s.name)
+	if err := s.start(); err != nil {
+		log.Warn("Server unavailable", "name", s.name, "error", err)
+		return
+	}
+	log.Info("Server connected", "name", s.name)
+
+	s.mu.Lock()
+	connected := append([]func(){}, s.connected...)
+	s.mu.Unlock()
+	for _, fn := range connected {
+		fn()
+	}
+}
+
+// dial opens a new connection. The connection lives until its context is
+// cancelled, which also stops the server process of stdio servers.
+func (s *supervisedClient) dial() (
+	*mcpclient.Client,
+	context.Context,
+	context.CancelCauseFunc,
+	error,
+) {
+	connCtx, connCancel := context.WithCancelCause(context.Background())
+	client, err := s.connect(connCtx)
+	if err != nil {
+		connCancel(err)
+		return nil, nil, nil, err
+	}
+	return client, connCtx, connCancel, nil
+}
+
+// setClient switches to a new connection. s.mu must be held.
+func (s *supervisedClient) setClient(
+	client *mcpclient.Client,
+	connCtx context.Context,
+	connCancel context.CancelCauseFunc,
+) {
+	s.client = client
+	s.connCtx, s.connCancel = connCtx, connCancel
+	s.state = serverConnected
+	s.since = time.Now()
+
+	client.OnNotification(func(notification mcp.JSONRPCNotification) {
+		s.mu.Lock()
+		handlers := append([]func(mcp.JSONRPCNotification){}, s.handlers...)
+		s.mu.Unlock()
+		for _, handler := range handlers {
+			handler(notification)
+		}
+	})
+	client.OnConnectionLost(func(err error) {
+		s.lost(client, err)
+	})
+	if stderr, ok := mcpclient.GetStderr(client); ok {
+		go s.watchStderr(client, stderr)
+	}
+}
+
+// watchStderr logs the stderr output of a stdio server. The output ends when
+// the server process exits.
+func (s *supervisedClient) watchStderr(client *mcpclient.Client, stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		log.Debug("Server output", "name", s.name, "stderr", scanner.Text())
+	}
+	s.lost(client, errors.New("server process exited"))
+}
+
+func (s *supervisedClient) watch() {
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.check()
+		}
+	}
+}
+
+// check pings the server and reconnects if it doesn't answer. A failed
+// server is tried again once it has been failed for failedRetryInterval.
+func (s *supervisedClient) check() {
+	s.mu.Lock()
+	client, state, since := s.client, s.state, s.since
+	s.mu.Unlock()
+	if state == serverFailed && time.Since(since) >= failedRetryInterval {
+		s.retry()
+	}
+	if state != serverConnected {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		s.lost(client, err)
+	}
+}
+
+// lost starts reconnecting after the connection of client failed. Failures
+// of a connection that was already replaced are ignored.
+func (s *supervisedClient) lost(client *mcpclient.Client, err error) {
+	s.mu.Lock()
+	if s.closed || s.client != client || s.state != serverConnected {
+		s.mu.Unlock()
+		return
+	}
+	s.state = serverRestarting
+	s.since = time.Now()
+	s.lastErr = err
+	s.connCancel(fmt.Errorf("connection to server %s lost: %w", s.name, err))
+	s.mu.Unlock()
+
+	log.Warn("Server connection lost", "name", s.name, "error", err)
+	go s.reconnect(client)
+}
+
+// retry reconnects to a server that failed before
+func (s *supervisedClient) retry() {
+	s.mu.Lock()
+	if s.closed || s.state != serverFailed {
+		s.mu.Unlock()
+		return
+	}
+	s.state = serverRestarting
+	s.since = time.Now()
+	s.mu.Unlock()
+
+	go s.reconnect(nil)
+}
+
+func (s *supervisedClient) reconnect(old *mcpclient.Client) {
+	if old != nil {
+		_ = old.Close()
+	}
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		client, connCtx, connCancel, err := s.dial()
+		if err == nil {
+			s.mu.Lock()
+			if s.closed {
+				s.mu.Unlock()
+				connCancel(fmt.Errorf("server %s closed", s.name))
+				_ = client.Close()
+				return
+			}
+			s.restarts++
+			s.setClient(client, connCtx, connCancel)
+			connected := append([]func(){}, s.connected...)
+			s.mu.Unlock()
+
+			log.Info("Server reconnected", "name", s.name, "attempt", attempt)
+			for _, fn := range connected {
+				fn()
+			}
+			return
+		}
+
+		s.mu.Lock()
+		s.lastErr = err
+		s.mu.Unlock()
+		log.Warn("Failed to reconnect server",
+			"name", s.name,
+			"attempt", attempt,
+			"error", err)
+		if attempt == maxRetries {
+			break
+		}
+
+		select {
+		case <-s.done:
+			return
+		case <-time.After(reconnectDelay(attempt)):
+		}
+	}
+
+	s.mu.Lock()
+	s.state = serverFailed
+	s.since = time.Now()
+	s.mu.Unlock()
+	log.Error("Server failed", "name", s.name, "error", s.status().LastErr)
+}
+
+// reconnectDelay returns how long to wait after the failed attempt-th
+// attempt to reconnect. The wait doubles from initialBackoff up to
+// maxBackoff.
+var reconnectDelay = func(attempt int) time.Duration {
+	backoff := initialBackoff
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+// onConnect registers fn to run whenever the server connects from now on,
+// when a lazy server starts or after a reconnect
+func (s *supervisedClient) onConnect(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected = append(s.connected, fn)
+}
+
+func (s *supervisedClient) status() serverStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return serverStatus{
+		State:    s.state,
+		Since:    s.since,
+		Restarts: s.restarts,
+		LastErr:  s.lastErr,
+	}
+}
+
+// conn returns the current connection and its context, starting a lazy
+// server first
+func (s *supervisedClient) conn() (*mcpclient.Client, context.Context, error) {
+	started := false
+	if s.status().State == serverNotStarted {
+		s.startLazy()
+		started = true
+	}
+
+	s.mu.Lock()
+	client, state, lastErr, connCtx := s.client, s.state, s.lastErr, s.connCtx
+	s.mu.Unlock()
+
+	switch state {
+	case serverRestarting:
+		return nil, nil, fmt.Errorf("server %s is restarting", s.name)
+	case serverFailed:
+		if !started {
+			s.retry()
+		}
+		return nil, nil, fmt.Errorf("server %s is unavailable: %w", s.name, lastErr)
+	}
+	return client, connCtx, nil
+}
+
+// call runs a request on the current connection of s. The request is
+// cancelled when the connection is lost, and transport errors make the
+// supervisor check on the server.
+func call[T any](
+	s *supervisedClient,
+	ctx context.Context,
+	fn func(client *mcpclient.Client, ctx context.Context) (T, error),
+) (T, error) {
+	var zero T
+	client, connCtx, err := s.conn()
+	if err != nil {
+		return zero, err
+	}
+
+	callCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(connCtx, cancel)
+	defer stop()
+
+	result, err := fn(client, callCtx)
+	if err != nil && ctx.Err() == nil {
+		if cause := context.Cause(connCtx); cause != nil {
+			return zero, cause
+		}
+		var transportErr *transport.Error
+		if errors.As(err, &transportErr) {
+			go s.check()
+		}
+	}
+	return result, err
+}
+
+func (s *supervisedClient) current() *mcpclient.Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.client
+}
+
+func (s *supervisedClient) Initialize(
+	ctx context.Context,
+	request mcp.InitializeRequest,
+) (*mcp.InitializeResult, error) {
+	return call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (*mcp.InitializeResult, error) {
+		return c.Initialize(ctx, request)
+	})
+}
+
+func (s *supervisedClient) Ping(ctx context.Context) error {
+	_, err := call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (struct{}, error) {
+		return struct{}{}, c.Ping(ctx)
+	})
+	return err
+}
+
+func (s *supervisedClient) ListResourcesByPage(
+	ctx context.Context,
+	request mcp.ListResourcesRequest,
+) (*mcp.ListResourcesResult, error) {
+	return call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (*mcp.ListResourcesResult, error) {
+		return c.ListResourcesByPage(ctx, request)
+	})
+}
+
+func (s *supervisedClient) ListResources(
+	ctx context.Context,
+	request mcp.ListResourcesRequest,
+) (*mcp.ListResourcesResult, error) {
+	return call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (*mcp.ListResourcesResult, error) {
+		return c.ListResources(ctx, request)
+	})
+}
+
+func (s *supervisedClient) ListResourceTemplatesByPage(
+	ctx context.Context,
+	request mcp.ListResourceTemplatesRequest,
+) (*mcp.ListResourceTemplatesResult, error) {
+	return call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (*mcp.ListResourceTemplatesResult, error) {
+		return c.ListResourceTemplatesByPage(ctx, request)
+	})
+}
+
+func (s *supervisedClient) ListResourceTemplates(
+	ctx context.Context,
+	request mcp.ListResourceTemplatesRequest,
+) (*mcp.ListResourceTemplatesResult, error) {
+	return call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (*mcp.ListResourceTemplatesResult, error) {
+		return c.ListResourceTemplates(ctx, request)
+	})
+}
+
+func (s *supervisedClient) ReadResource(
+	ctx context.Context,
+	request mcp.ReadResourceRequest,
+) (*mcp.ReadResourceResult, error) {
+	return call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (*mcp.ReadResourceResult, error) {
+		return c.ReadResource(ctx, request)
+	})
+}
+
+func (s *supervisedClient) Subscribe(ctx context.Context, request mcp.SubscribeRequest) error {
+	_, err := call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (struct{}, error) {
+		return struct{}{}, c.Subscribe(ctx, request)
+	})
+	return err
+}
+
+func (s *supervisedClient) Unsubscribe(ctx context.Context, request mcp.UnsubscribeRequest) error {
+	_, err := call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (struct{}, error) {
+		return struct{}{}, c.Unsubscribe(ctx, request)
+	})
+	return err
+}
+
+func (s *supervisedClient) ListPromptsByPage(
+	ctx context.Context,
+	request mcp.ListPromptsRequest,
+) (*mcp.ListPromptsResult, error) {
+	return call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (*mcp.ListPromptsResult, error) {
+		return c.ListPromptsByPage(ctx, request)
+	})
+}
+
+func (s *supervisedClient) ListPrompts(
+	ctx context.Context,
+	request mcp.ListPromptsRequest,
+) (*mcp.ListPromptsResult, error) {
+	return call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (*mcp.ListPromptsResult, error) {
+		return c.ListPrompts(ctx, request)
+	})
+}
+
+func (s *supervisedClient) GetPrompt(
+	ctx context.Context,
+	request mcp.GetPromptRequest,
+) (*mcp.GetPromptResult, error) {
+	return call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (*mcp.GetPromptResult, error) {
+		return c.GetPrompt(ctx, request)
+	})
+}
+
+func (s *supervisedClient) ListToolsByPage(
+	ctx context.Context,
+	request mcp.ListToolsRequest,
+) (*mcp.ListToolsResult, error) {
+	return call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (*mcp.ListToolsResult, error) {
+		return c.ListToolsByPage(ctx, request)
+	})
+}
+
+func (s *supervisedClient) ListTools(
+	ctx context.Context,
+	request mcp.ListToolsRequest,
+) (*mcp.ListToolsResult, error) {
+	return call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (*mcp.ListToolsResult, error) {
+		return c.ListTools(ctx, request)
+	})
+}
+
+// toolCallIDs numbers the tool calls sent by mcphost itself. They are
+// strings so they can't clash with the numbers the client uses.
+var toolCallIDs atomic.Int64
+
+// CallTool runs a tool. Unlike the client, it tells the server when the
+// call is given up on, e.g. when it times out, so that the server can stop
+// working on it.
+func (s *supervisedClient) CallTool(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	return call(s, ctx, func(c *mcpclient.Client, callCtx context.Context) (*mcp.CallToolResult, error) {
+		id := mcp.NewRequestId(fmt.Sprintf("mcphost-%d", toolCallIDs.Add(1)))
+		response, err := c.GetTransport().SendRequest(callCtx, transport.JSONRPCRequest{
+			JSONRPC: mcp.JSONRPC_VERSION,
+			ID:      id,
+			Method:  string(mcp.MethodToolsCall),
+			Params:  request.Params,
+			Header:  request.Header,
+		})
+		if ctx.Err() != nil {
+			s.cancelRequest(c, id, context.Cause(ctx))
+			return nil, context.Cause(ctx)
+		}
+		if err != nil {
+			return nil, transport.NewError(err)
+		}
+		if response.Error != nil {
+			return nil, response.Error.AsError()
+		}
+		return mcp.ParseCallToolResult(&response.Result)
+	})
+}
+
+// cancelRequest sends the server a cancellation notification for a request
+func (s *supervisedClient) cancelRequest(client *mcpclient.Client, id mcp.RequestId, reason error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	notification := mcp.JSONRPCNotification{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{
+			Method: "notifications/cancelled",
+			Params: mcp.NotificationParams{
+				AdditionalFields: map[string]any{
+					"requestId": id,
+					"reason":    reason.Error(),
+				},
+			},
+		},
+	}
+	if err := client.GetTransport().SendNotification(ctx, notification); err != nil {
+		log.Debug("Error sending cancellation", "server", s.name, "error", err)
+	}
+}
+
+func (s *supervisedClient) SetLevel(ctx context.Context, request mcp.SetLevelRequest) error {
+	_, err := call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (struct{}, error) {
+		return struct{}{}, c.SetLevel(ctx, request)
+	})
+	return err
+}
+
+func (s *supervisedClient) Complete(
+	ctx context.Context,
+	request mcp.CompleteRequest,
+) (*mcp.CompleteResult, error) {
+	return call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (*mcp.CompleteResult, error) {
+		return c.Complete(ctx, request)
+	})
+}
+
+// RootListChanges tells the server that the roots have changed. Servers
+// that haven't started yet get the roots when they start.
+func (s *supervisedClient) RootListChanges(ctx context.Context) error {
+	if s.status().State == serverNotStarted {
+		return nil
+	}
+	_, err := call(s, ctx, func(c *mcpclient.Client, ctx context.Context) (struct{}, error) {
+		return struct{}{}, c.RootListChanges(ctx)
+	})
+	return err
+}
+
+// GetServerCapabilities returns the capabilities of the current connection
+func (s *supervisedClient) GetServerCapabilities() mcp.ServerCapabilities {
+	if client := s.current(); client != nil {
+		return client.GetServerCapabilities()
+	}
+	return mcp.ServerCapabilities{}
+}
+
+// OnNotification registers a handler for the notifications of the server,
+// on the current connection and all later ones
+func (s *supervisedClient) OnNotification(handler func(notification mcp.JSONRPCNotification)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, handler)
+}
+
+// Close stops supervising the server and closes the connection
+func (s *supervisedClient) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	client := s.client
+	if s.connCancel != nil {
+		s.connCancel(fmt.Errorf("server %s closed", s.name))
+	}
+	s.mu.Unlock()
+
+	if client == nil {
+		return nil
+	}
+	return client.Close()
+}
+
+// transportLogger logs the messages of a stdio transport at debug level.
+// Failures of the server are reported by the supervisor instead.
+type transportLogger struct {
+	name string
+}
+
+func (l transportLogger) Infof(format string, v ...any) {
+	log.Debug(fmt.Sprintf(format, v...), "name", l.name)
+}
+
+func (l transportLogger) Errorf(format string, v ...any) {
+	log.Debug(fmt.Sprintf(format, v...), "name", l.name)
+}
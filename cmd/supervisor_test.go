@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestReconnectDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: initialBackoff},
+		{attempt: 2, want: 2 * initialBackoff},
+		{attempt: 3, want: 4 * initialBackoff},
+		{attempt: 5, want: 16 * initialBackoff},
+		{attempt: 6, want: maxBackoff},
+		{attempt: 100, want: maxBackoff},
+	}
+
+	for _, test := range tests {
+		if got := reconnectDelay(test.attempt); got != test.want {
+			t.Errorf("reconnectDelay(%d) = %s, want %s", test.attempt, got, test.want)
+		}
+	}
+}
+
+// testServer counts the connections to an in process server, whose
+// connections fail while failures are left
+type testServer struct {
+	mu       sync.Mutex
+	dials    int
+	failures int
+}
+
+func (s *testServer) connect(ctx context.Context) (*mcpclient.Client, error) {
+	s.mu.Lock()
+	s.dials++
+	failing := s.failures > 0
+	if failing {
+		s.failures--
+	}
+	s.mu.Unlock()
+	if failing {
+		return nil, errors.New("connection refused")
+	}
+	return startTestClient(echoTool)
+}
+
+// waitState waits until the server leaves the restarting state
+func waitState(t *testing.T, s *supervisedClient) serverStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if status := s.status(); status.State != serverRestarting {
+			return status
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("server still restarting")
+	return serverStatus{}
+}
+
+func TestSupervisedClientReconnect(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		wantState string
+	}{
+		{name: "first attempt", wantState: serverConnected},
+		{name: "after failures", failures: maxRetries - 1, wantState: serverConnected},
+		{name: "gives up", failures: maxRetries, wantState: serverFailed},
+	}
+
+	original := reconnectDelay
+	reconnectDelay = func(attempt int) time.Duration { return time.Millisecond }
+	t.Cleanup(func() { reconnectDelay = original })
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &testServer{}
+			s := newSupervisedClient("echo", server.connect)
+			t.Cleanup(func() { s.Close() })
+			if err := s.start(); err != nil {
+				t.Fatalf("start() error = %v", err)
+			}
+
+			reconnected := make(chan struct{}, 1)
+			s.onConnect(func() { reconnected <- struct{}{} })
+
+			server.mu.Lock()
+			server.failures = test.failures
+			server.mu.Unlock()
+			old := s.current()
+			s.lost(old, errors.New("server process exited"))
+
+			status := waitState(t, s)
+			if status.State != test.wantState {
+				t.Fatalf("state = %s (%v), want %s", status.State, status.LastErr, test.wantState)
+			}
+			server.mu.Lock()
+			dials := server.dials
+			server.mu.Unlock()
+			if want := 1 + min(test.failures+1, maxRetries); dials != want {
+				t.Errorf("%d connections, want %d", dials, want)
+			}
+
+			if test.wantState == serverFailed {
+				if status.Restarts != 0 {
+					t.Errorf("%d restarts, want none", status.Restarts)
+				}
+				_, err := s.ListTools(context.Background(), mcp.ListToolsRequest{})
+				if err == nil || !strings.Contains(err.Error(), "connection refused") {
+					t.Errorf("ListTools() error = %v, want the last error", err)
+				}
+				return
+			}
+
+			select {
+			case <-reconnected:
+			case <-time.After(5 * time.Second):
+				t.Fatal("connect handlers not run")
+			}
+			if status.Restarts != 1 {
+				t.Errorf("%d restarts, want 1", status.Restarts)
+			}
+			if _, err := s.ListTools(context.Background(), mcp.ListToolsRequest{}); err != nil {
+				t.Errorf("ListTools() error = %v", err)
+			}
+
+			// A failure of the connection that was replaced is ignored
+			s.lost(old, errors.New("server process exited"))
+			if status := s.status(); status.State != serverConnected || status.Restarts != 1 {
+				t.Errorf("state = %s with %d restarts, want connected with 1", status.State, status.Restarts)
+			}
+		})
+	}
+}
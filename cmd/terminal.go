@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+
+	"github.com/charmbracelet/huh/spinner"
+	"golang.org/x/term"
+)
+
+// MCP servers can ask the user something while a tool call is running, for
+// example to approve a sampling request. The spinner of the tool call owns
+// the terminal then, so it is stopped for as long as the question is shown.
+var (
+	// formMu is held while a form is shown from outside the main loop
+	formMu sync.Mutex
+
+	spinnerMu     sync.Mutex
+	activeSpinner *runningSpinner
+)
+
+// errInterrupted is the cause of a prompt cancelled with Ctrl+C
+var errInterrupted = errors.New("cancelled by the user")
+
+var (
+	interruptMu sync.Mutex
+	interrupt   func()
+)
+
+// interruptible returns a context for running a prompt that Ctrl+C cancels
+// with errInterrupted. Ctrl+C is a signal while text is printed, and a key
+// while a spinner is shown. stop must be called once the prompt is done.
+func interruptible(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+			cancel(errInterrupted)
+		case <-done:
+		}
+	}()
+
+	interruptMu.Lock()
+	interrupt = func() { cancel(errInterrupted) }
+	interruptMu.Unlock()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		interruptMu.Lock()
+		interrupt = nil
+		interruptMu.Unlock()
+		cancel(nil)
+	}
+}
+
+type runningSpinner struct {
+	cancel  context.CancelFunc
+	stopped chan struct{}
+}
+
+// runWithSpinner runs action while a spinner is shown. Unlike a plain
+// spinner, it steps aside for forms shown with withTerminal, and Ctrl+C
+// cancels the prompt that is running, if any.
+func runWithSpinner(title string, action func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		action()
+	}()
+
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		s := &runningSpinner{cancel: cancel, stopped: make(chan struct{})}
+
+		// Wait for a form that is being shown, and keep new ones from
+		// starting until they can see the spinner
+		formMu.Lock()
+		spinnerMu.Lock()
+		activeSpinner = s
+		spinnerMu.Unlock()
+		formMu.Unlock()
+
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		err := spinner.New().Title(title).Context(ctx).Run()
+		// The spinner only stops by itself when Ctrl+C is pressed
+		if err == nil && ctx.Err() == nil && term.IsTerminal(int(os.Stdin.Fd())) {
+			interruptMu.Lock()
+			fn := interrupt
+			interruptMu.Unlock()
+			if fn != nil {
+				fn()
+			}
+		}
+		// The spinner returns early when it can't draw, e.g. without a
+		// terminal. Wait for the action or a form anyway.
+		<-ctx.Done()
+
+		spinnerMu.Lock()
+		activeSpinner = nil
+		spinnerMu.Unlock()
+		close(s.stopped)
+
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+
+// startSpinner shows a spinner, like runWithSpinner, until the returned
+// function is called
+func startSpinner(title string) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		runWithSpinner(title, func() { <-done })
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// withTerminal runs fn, which shows a form, after stopping the spinner of
+// runWithSpinner if one is running. Only one such form is shown at a time.
+func withTerminal(fn func()) {
+	formMu.Lock()
+	defer formMu.Unlock()
+
+	spinnerMu.Lock()
+	s := activeSpinner
+	spinnerMu.Unlock()
+	if s != nil {
+		s.cancel()
+		<-s.stopped
+	}
+
+	fn()
+}
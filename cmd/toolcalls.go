@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/mark3labs/mcphost/pkg/agent"
+)
+
+// hostExecutor approves the tool calls of the model with the approver and
+// runs them on the MCP servers, or with mcphost's own tools. With a
+// spinner, the calls run while it is shown.
+type hostExecutor struct {
+	config     *MCPConfig
+	mcpClients map[string]mcpclient.MCPClient
+	approver   *toolApprover
+	spinner    bool
+}
+
+func (e *hostExecutor) Approve(ctx context.Context, call *agent.ToolCall) (bool, string) {
+	if !hasServer(e.mcpClients, call.Server) {
+		return false, fmt.Sprintf("Server not found: %s", call.Server)
+	}
+	return e.approver.approve(call.Server, call.Tool, call.Arguments)
+}
+
+func (e *hostExecutor) Run(ctx context.Context, calls []*agent.ToolCall) {
+	action := func() {
+		runToolCalls(ctx, e.config, e.mcpClients, calls)
+	}
+	if !e.spinner {
+		action()
+		return
+	}
+	runWithSpinner(toolCallsTitle(calls), action)
+}
+
+// sequentialServers holds a lock for each sequential server, so that its
+// tool calls don't overlap even when they come from different sessions of
+// the HTTP server
+var sequentialServers = struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}{locks: make(map[string]*sync.Mutex)}
+
+func sequentialLock(serverName string) *sync.Mutex {
+	sequentialServers.mu.Lock()
+	defer sequentialServers.mu.Unlock()
+
+	lock, ok := sequentialServers.locks[serverName]
+	if !ok {
+		lock = &sync.Mutex{}
+		sequentialServers.locks[serverName] = lock
+	}
+	return lock
+}
+
+// runToolCalls runs the tool calls the model made in one turn at the same
+// time, at most toolConcurrency of them at once. The calls of a sequential
+// server run one after another in the order the model made them.
+func runToolCalls(
+	ctx context.Context,
+	config *MCPConfig,
+	mcpClients map[string]mcpclient.MCPClient,
+	calls []*agent.ToolCall,
+) {
+	// Each lane runs its calls in order, and lanes run at the same time
+	var lanes [][]*agent.ToolCall
+	sequential := make(map[string]int)
+	for _, call := range calls {
+		if !config.serverOptions(call.Server).Sequential {
+			lanes = append(lanes, []*agent.ToolCall{call})
+			continue
+		}
+		if i, ok := sequential[call.Server]; ok {
+			lanes[i] = append(lanes[i], call)
+			continue
+		}
+		sequential[call.Server] = len(lanes)
+		lanes = append(lanes, []*agent.ToolCall{call})
+	}
+
+	settingsMu.RLock()
+	limit := toolConcurrency
+	settingsMu.RUnlock()
+	if limit < 1 {
+		limit = 1
+	}
+	slots := make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	for _, lane := range lanes {
+		wg.Add(1)
+		go func(lane []*agent.ToolCall) {
+			defer wg.Done()
+			for _, call := range lane {
+				runToolCall(ctx, config, mcpClients, call, slots)
+			}
+		}(lane)
+	}
+	wg.Wait()
+}
+
+func runToolCall(
+	ctx context.Context,
+	config *MCPConfig,
+	mcpClients map[string]mcpclient.MCPClient,
+	call *agent.ToolCall,
+	slots chan struct{},
+) {
+	// The server is locked before a slot is taken, so that calls waiting
+	// for a sequential server don't keep other calls from running
+	if config.serverOptions(call.Server).Sequential {
+		lock := sequentialLock(call.Server)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-ctx.Done():
+		call.Result = cancelledResult(ctx)
+		return
+	}
+
+	timeout := config.toolTimeout(call.Server, call.Tool)
+	callCtx, cancel := context.WithTimeoutCause(ctx, timeout, errToolTimeout)
+	defer cancel()
+
+	result, err := callTool(
+		callCtx,
+		mcpClients,
+		call.Server,
+		call.Tool,
+		call.Arguments,
+	)
+	switch {
+	case err == nil:
+		call.Result = result
+	case ctx.Err() != nil:
+		call.Result = cancelledResult(ctx)
+	case errors.Is(context.Cause(callCtx), errToolTimeout):
+		log.Warn("Tool call timed out", "server", call.Server, "tool", call.Tool, "timeout", timeout)
+		call.Result = toolErrorResult(toolCallError{
+			Type:    "timeout",
+			Message: fmt.Sprintf("The tool call did not finish within %s and was cancelled.", timeout),
+			Timeout: timeout.Seconds(),
+		})
+	default:
+		call.Err = err
+	}
+}
+
+var errToolTimeout = errors.New("tool call timed out")
+
+// toolCallError is sent to the model as the result of a tool call that
+// timed out or was cancelled, so that it can tell these apart from errors
+// of the tool itself
+type toolCallError struct {
+	// Type is "timeout" or "cancelled"
+	Type    string  `json:"type"`
+	Message string  `json:"message"`
+	Timeout float64 `json:"timeoutSeconds,omitempty"`
+}
+
+func toolErrorResult(e toolCallError) *mcp.CallToolResult {
+	data, err := json.Marshal(map[string]toolCallError{"error": e})
+	if err != nil {
+		return mcp.NewToolResultError(e.Message)
+	}
+	return mcp.NewToolResultError(string(data))
+}
+
+// cancelledResult is the result of a tool call that was cancelled with ctx
+func cancelledResult(ctx context.Context) *mcp.CallToolResult {
+	message := "The tool call was cancelled."
+	if errors.Is(context.Cause(ctx), errInterrupted) {
+		message = "The user cancelled the tool call."
+	}
+	return toolErrorResult(toolCallError{Type: "cancelled", Message: message})
+}
+
+// toolCallsTitle is the title of the spinner shown while tool calls run
+func toolCallsTitle(calls []*agent.ToolCall) string {
+	if len(calls) == 1 {
+		return fmt.Sprintf("Running tool %s...", calls[0].Tool)
+	}
+	return fmt.Sprintf("Running %d tools...", len(calls))
+}
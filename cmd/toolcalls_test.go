@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/mark3labs/mcphost/pkg/agent"
+)
+
+// useToolConcurrency sets the most tool calls that run at once for a test
+func useToolConcurrency(t *testing.T, limit int) {
+	t.Helper()
+	original := toolConcurrency
+	toolConcurrency = limit
+	t.Cleanup(func() { toolConcurrency = original })
+}
+
+// workLog records the tool calls of work tools as they start and end
+type workLog struct {
+	mu         sync.Mutex
+	events     []string
+	running    int
+	maxRunning int
+}
+
+// workTool returns a tool that takes a while and answers with its id
+func (l *workLog) workTool() server.ServerTool {
+	return server.ServerTool{
+		Tool: mcp.NewTool("work", mcp.WithString("id")),
+		Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			id := request.GetString("id", "")
+			l.mu.Lock()
+			l.events = append(l.events, "start "+id)
+			l.running++
+			l.maxRunning = max(l.maxRunning, l.running)
+			l.mu.Unlock()
+
+			time.Sleep(50 * time.Millisecond)
+
+			l.mu.Lock()
+			l.events = append(l.events, "end "+id)
+			l.running--
+			l.mu.Unlock()
+			return mcp.NewToolResultText(id), nil
+		},
+	}
+}
+
+// waitTool runs until its call is cancelled
+var waitTool = server.ServerTool{
+	Tool: mcp.NewTool("wait"),
+	Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return mcp.NewToolResultText("done"), nil
+		}
+	},
+}
+
+func resultText(result *mcp.CallToolResult) string {
+	if result == nil || len(result.Content) == 0 {
+		return ""
+	}
+	if text, ok := result.Content[0].(mcp.TextContent); ok {
+		return text.Text
+	}
+	return ""
+}
+
+func TestRunToolCalls(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		// calls are the servers called, in order
+		calls          []string
+		wantMaxRunning int
+	}{
+		{name: "parallel", limit: 4, calls: []string{"par", "par", "par"}, wantMaxRunning: 3},
+		{name: "limit", limit: 2, calls: []string{"par", "par", "par", "par"}, wantMaxRunning: 2},
+		{name: "sequential", limit: 4, calls: []string{"seq", "seq", "seq"}, wantMaxRunning: 1},
+		// The sequential calls run one after another, next to the others
+		{name: "mixed", limit: 4, calls: []string{"seq", "par", "seq", "par"}, wantMaxRunning: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useToolConcurrency(t, test.limit)
+			log := &workLog{}
+			mcpClients := map[string]mcpclient.MCPClient{
+				"par": newTestClient(t, log.workTool()),
+				"seq": newTestClient(t, log.workTool()),
+			}
+			config := &MCPConfig{MCPServers: map[string]ServerConfigWrapper{
+				"par": {Config: STDIOServerConfig{Command: "par"}},
+				"seq": {Config: STDIOServerConfig{
+					Command:       "seq",
+					ServerOptions: ServerOptions{Sequential: true},
+				}},
+			}}
+
+			calls := make([]*agent.ToolCall, len(test.calls))
+			for i, serverName := range test.calls {
+				calls[i] = &agent.ToolCall{
+					Server:    serverName,
+					Tool:      "work",
+					Arguments: map[string]interface{}{"id": fmt.Sprintf("%s%d", serverName, i)},
+				}
+			}
+			runToolCalls(context.Background(), config, mcpClients, calls)
+
+			// Each call gets its own result
+			for i, call := range calls {
+				if call.Err != nil {
+					t.Fatalf("call %d error = %v", i, call.Err)
+				}
+				if got, want := resultText(call.Result), call.Arguments["id"]; got != want {
+					t.Errorf("result of call %d = %q, want %q", i, got, want)
+				}
+			}
+			if log.maxRunning != test.wantMaxRunning {
+				t.Errorf("%d calls ran at once, want %d", log.maxRunning, test.wantMaxRunning)
+			}
+
+			// The calls of the sequential server don't overlap and keep
+			// their order
+			var sequential []string
+			for _, event := range log.events {
+				if _, id, _ := strings.Cut(event, " "); strings.HasPrefix(id, "seq") {
+					sequential = append(sequential, event)
+				}
+			}
+			var want []string
+			for i, serverName := range test.calls {
+				if serverName == "seq" {
+					want = append(want, fmt.Sprintf("start seq%d", i), fmt.Sprintf("end seq%d", i))
+				}
+			}
+			if fmt.Sprint(sequential) != fmt.Sprint(want) {
+				t.Errorf("sequential calls = %q, want %q", sequential, want)
+			}
+		})
+	}
+}
+
+func TestRunToolCallsInterrupted(t *testing.T) {
+	tests := []struct {
+		name string
+		// cancel cancels the context of the calls, unless it is nil
+		cancel func(cancel context.CancelCauseFunc)
+		want   toolCallError
+	}{
+		{
+			name: "timeout",
+			want: toolCallError{
+				Type:    "timeout",
+				Message: "The tool call did not finish within 1s and was cancelled.",
+				Timeout: 1,
+			},
+		},
+		{
+			name:   "cancelled",
+			cancel: func(cancel context.CancelCauseFunc) { cancel(nil) },
+			want:   toolCallError{Type: "cancelled", Message: "The tool call was cancelled."},
+		},
+		{
+			name:   "cancelled by the user",
+			cancel: func(cancel context.CancelCauseFunc) { cancel(errInterrupted) },
+			want:   toolCallError{Type: "cancelled", Message: "The user cancelled the tool call."},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mcpClients := map[string]mcpclient.MCPClient{"slow": newTestClient(t, waitTool)}
+			config := &MCPConfig{MCPServers: map[string]ServerConfigWrapper{
+				"slow": {Config: STDIOServerConfig{
+					Command:       "slow",
+					ServerOptions: ServerOptions{ToolTimeout: 1},
+				}},
+			}}
+
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+			if test.cancel != nil {
+				time.AfterFunc(10*time.Millisecond, func() { test.cancel(cancel) })
+			}
+			call := &agent.ToolCall{Server: "slow", Tool: "wait"}
+			runToolCalls(ctx, config, mcpClients, []*agent.ToolCall{call})
+
+			if call.Err != nil {
+				t.Fatalf("call error = %v, want a result", call.Err)
+			}
+			if !call.Result.IsError {
+				t.Errorf("result is not an error")
+			}
+			var got map[string]toolCallError
+			if err := json.Unmarshal([]byte(resultText(call.Result)), &got); err != nil {
+				t.Fatalf("result %q: %v", resultText(call.Result), err)
+			}
+			if got["error"] != test.want {
+				t.Errorf("error = %+v, want %+v", got["error"], test.want)
+			}
+		})
+	}
+}
+
+func TestToolTimeout(t *testing.T) {
+	original := toolTimeout
+	toolTimeout = 30
+	t.Cleanup(func() { toolTimeout = original })
+
+	config := &MCPConfig{MCPServers: map[string]ServerConfigWrapper{
+		"build": {Config: STDIOServerConfig{
+			Command: "build",
+			ServerOptions: ServerOptions{
+				ToolTimeout:  60,
+				ToolTimeouts: map[string]int{"compile_*": 600, "compile_fast": 5},
+			},
+		}},
+		"fs": {Config: STDIOServerConfig{Command: "fs"}},
+	}}
+
+	tests := []struct {
+		server string
+		tool   string
+		want   time.Duration
+	}{
+		{server: "build", tool: "compile_fast", want: 5 * time.Second},
+		{server: "build", tool: "compile_all", want: 10 * time.Minute},
+		{server: "build", tool: "clean", want: time.Minute},
+		{server: "fs", tool: "read_file", want: 30 * time.Second},
+		{server: "unknown", tool: "read_file", want: 30 * time.Second},
+	}
+
+	for _, test := range tests {
+		if got := config.toolTimeout(test.server, test.tool); got != test.want {
+			t.Errorf("toolTimeout(%q, %q) = %s, want %s", test.server, test.tool, got, test.want)
+		}
+	}
+}
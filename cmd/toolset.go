@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/mark3labs/mcphost/pkg/agent"
+	"github.com/mark3labs/mcphost/pkg/llm"
+)
+
+// toolSet holds the tools offered to the model. The tools of a server are
+// loaded again when the server reports that they changed.
+type toolSet struct {
+	config *MCPConfig
+
+	mu      sync.RWMutex
+	servers map[string][]llm.Tool
+	host    []llm.Tool
+}
+
+func newToolSet(config *MCPConfig) *toolSet {
+	return &toolSet{
+		config:  config,
+		servers: make(map[string][]llm.Tool),
+	}
+}
+
+// load fetches the tools of a server, replacing the ones loaded before
+func (t *toolSet) load(
+	ctx context.Context,
+	serverName string,
+	mcpClient mcpclient.MCPClient,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	toolsResult, err := mcpClient.ListTools(ctx, mcp.ListToolsRequest{})
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	server, _ := t.config.serverConfig(serverName)
+	options := t.config.serverOptions(serverName)
+	if options.Lazy {
+		if err := saveToolCache(serverName, server, toolsResult.Tools); err != nil {
+			log.Debug("Error caching tools", "server", serverName, "error", err)
+		}
+	}
+
+	enabledTools := filterTools(options, toolsResult.Tools)
+	t.mu.Lock()
+	t.servers[serverName] = agent.ServerTools(serverName, enabledTools)
+	t.mu.Unlock()
+
+	log.Info(
+		"Tools loaded",
+		"server",
+		serverName,
+		"count",
+		len(enabledTools),
+		"disabled",
+		len(toolsResult.Tools)-len(enabledTools),
+	)
+	return nil
+}
+
+// loadAll loads the tools of the servers. Lazy servers offer their cached
+// tools and aren't started. A lazy server without cached tools is started
+// in the background to list them, since the model can't call tools it
+// doesn't know about. The tools of failed servers are loaded when they
+// connect.
+func (t *toolSet) loadAll(ctx context.Context, mcpClients map[string]mcpclient.MCPClient) {
+	for serverName, mcpClient := range mcpClients {
+		if t.config.serverOptions(serverName).Lazy {
+			if !t.loadCached(serverName) {
+				log.Info("Lazy server has no cached tools, starting it to list them", "server", serverName)
+				go func() {
+					if err := t.load(context.Background(), serverName, mcpClient); err != nil {
+						log.Error("Error fetching tools", "server", serverName, "error", err)
+					}
+				}()
+			}
+			continue
+		}
+		if sc, ok := mcpClient.(*supervisedClient); ok && sc.status().State == serverFailed {
+			continue
+		}
+		if err := t.load(ctx, serverName, mcpClient); err != nil {
+			log.Error(
+				"Error fetching tools",
+				"server",
+				serverName,
+				"error",
+				err,
+			)
+		}
+	}
+}
+
+// remove drops the tools of a server that was stopped
+func (t *toolSet) remove(serverName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.servers, serverName)
+}
+
+// loadCached uses the tools cached when a lazy server last ran, so the
+// model can call them before the server is started. It reports false if
+// there are none.
+func (t *toolSet) loadCached(serverName string) bool {
+	server, _ := t.config.serverConfig(serverName)
+	cachePath, err := toolCachePath(serverName, server)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return false
+	}
+	var cached []mcp.Tool
+	if err := json.Unmarshal(data, &cached); err != nil {
+		log.Debug("Error reading cached tools", "server", serverName, "error", err)
+		return false
+	}
+
+	enabledTools := filterTools(t.config.serverOptions(serverName), cached)
+	t.mu.Lock()
+	t.servers[serverName] = agent.ServerTools(serverName, enabledTools)
+	t.mu.Unlock()
+
+	log.Info("Cached tools loaded", "server", serverName, "count", len(enabledTools))
+	return true
+}
+
+// toolCachePath returns the file the tools of a lazy server are cached in,
+// $HOME/.mcphost/tools/<server>-<hash>.json. The hash is of the config of
+// the server, so a server whose config changed doesn't offer the tools of
+// the one it replaced.
+func toolCachePath(serverName string, server ServerConfigWrapper) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting home directory: %w", err)
+	}
+	data, err := json.Marshal(server)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	fileName := fmt.Sprintf("%s-%x.json", serverName, sum[:8])
+	return filepath.Join(homeDir, ".mcphost", "tools", fileName), nil
+}
+
+func saveToolCache(serverName string, server ServerConfigWrapper, tools []mcp.Tool) error {
+	cachePath, err := toolCachePath(serverName, server)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(tools, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cachePath, data, 0644)
+}
+
+// addHostTools adds tools that mcphost provides itself
+func (t *toolSet) addHostTools(tools ...llm.Tool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.host = append(t.host, tools...)
+}
+
+// all returns the tools of every server, ordered by server
+func (t *toolSet) all() []llm.Tool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	names := make([]string, 0, len(t.servers))
+	for name := range t.servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tools []llm.Tool
+	for _, name := range names {
+		tools = append(tools, t.servers[name]...)
+	}
+	return append(tools, t.host...)
+}
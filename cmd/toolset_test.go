@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// waitTools waits until the tool set offers tools
+func waitTools(t *testing.T, tools *toolSet) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if all := tools.all(); len(all) > 0 {
+			names := make([]string, len(all))
+			for i, tool := range all {
+				names[i] = tool.Name
+			}
+			return names
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("no tools loaded")
+	return nil
+}
+
+func TestToolSetLoadAll(t *testing.T) {
+	lazy := ServerConfigWrapper{Config: STDIOServerConfig{
+		Command:       "echo-server",
+		Args:          []string{"--verbose"},
+		ServerOptions: ServerOptions{Lazy: true},
+	}}
+
+	tests := []struct {
+		name   string
+		server ServerConfigWrapper
+		// cached is the config the cached tools were listed with
+		cached    *ServerConfigWrapper
+		wantTools string
+		wantDials int
+	}{
+		{
+			name:      "eager",
+			server:    ServerConfigWrapper{Config: STDIOServerConfig{Command: "echo-server"}},
+			wantTools: "echo__say",
+			wantDials: 1,
+		},
+		{
+			name:      "lazy cached",
+			server:    lazy,
+			cached:    &lazy,
+			wantTools: "echo__cached",
+		},
+		{
+			// The server is started in the background to list its tools
+			name:      "lazy without cache",
+			server:    lazy,
+			wantTools: "echo__say",
+			wantDials: 1,
+		},
+		{
+			// The cached tools belong to another config of the server
+			name:   "lazy config changed",
+			server: lazy,
+			cached: &ServerConfigWrapper{Config: STDIOServerConfig{
+				Command:       "echo-server",
+				ServerOptions: ServerOptions{Lazy: true},
+			}},
+			wantTools: "echo__say",
+			wantDials: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			if test.cached != nil {
+				if err := saveToolCache("echo", *test.cached, []mcp.Tool{mcp.NewTool("cached")}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			config := &MCPConfig{MCPServers: map[string]ServerConfigWrapper{"echo": test.server}}
+			server := &testServer{}
+			client := newSupervisedClient("echo", server.connect)
+			t.Cleanup(func() { client.Close() })
+			if !test.server.Config.GetOptions().Lazy {
+				if err := client.start(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tools := newToolSet(config)
+			tools.loadAll(context.Background(), map[string]mcpclient.MCPClient{"echo": client})
+			names := waitTools(t, tools)
+			if len(names) != 1 || names[0] != test.wantTools {
+				t.Errorf("tools = %q, want %s", names, test.wantTools)
+			}
+			server.mu.Lock()
+			dials := server.dials
+			server.mu.Unlock()
+			if dials != test.wantDials {
+				t.Errorf("%d connections, want %d", dials, test.wantDials)
+			}
+
+			// The tools of a lazy server that was started are cached for
+			// the next run
+			cachePath, err := toolCachePath("echo", test.server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = os.Stat(cachePath)
+			if wantCache := test.server.Config.GetOptions().Lazy; (err == nil) != wantCache {
+				t.Errorf("cache written = %v, want %v", err == nil, wantCache)
+			}
+		})
+	}
+}
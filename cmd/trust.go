@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var configTrustCmd = &cobra.Command{
+	Use:   "trust",
+	Short: "Trust the project config to start its servers and run its secret commands",
+	Long: `Trust the project config (.mcphost.json in the current directory or the
+closest parent that has one). The servers and secrets of a project config
+are only used once it is trusted, because it is picked up from whatever
+directory mcphost runs in. The trust is kept until the file changes.
+
+mcphost asks whether to trust a project config when it starts on a
+terminal. This command is for --server and other runs where nobody is
+there to ask.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := findProjectConfig()
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			return errors.New("no project config found in the current directory or its parents")
+		}
+		layer, err := readConfigFile(path, true)
+		if err != nil {
+			return err
+		}
+		if err := trustProject(path, layer.digest); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Trusted %s\n", path)
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configTrustCmd)
+}
+
+// projectCommands describes what a project config would run or connect
+// to: its servers and its secrets. Disabled servers are left out, as they
+// only turn off servers of the user config.
+func projectCommands(layer *MCPConfig) []string {
+	var commands []string
+	for name, server := range layer.MCPServers {
+		if server.Config.GetOptions().Disabled {
+			continue
+		}
+		switch config := server.Config.(type) {
+		case STDIOServerConfig:
+			commands = append(commands, fmt.Sprintf("server %s runs %s", name,
+				strings.Join(append([]string{config.Command}, config.Args...), " ")))
+		case SSEServerConfig:
+			commands = append(commands, fmt.Sprintf("server %s connects to %s", name, config.Url))
+		case StreamableHTTPServerConfig:
+			commands = append(commands, fmt.Sprintf("server %s connects to %s", name, config.Url))
+		}
+	}
+	if layer.Secrets != nil {
+		for name, command := range layer.Secrets.Commands {
+			commands = append(commands, fmt.Sprintf("secret %s runs %s", name, command))
+		}
+		for _, dotenv := range layer.Secrets.Dotenv {
+			commands = append(commands, fmt.Sprintf("secrets are read from %s", dotenv))
+		}
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+// checkProjectTrust makes sure the servers and secrets of a project config
+// are only used if the user trusts it. On a terminal the user is asked, and
+// the answer is kept until the file changes. Otherwise they are dropped
+// from the layer before it is merged.
+func (c *MCPConfig) checkProjectTrust(path string, layer *MCPConfig) {
+	commands := projectCommands(layer)
+	if len(commands) == 0 || projectTrusted(path, layer.digest) {
+		return
+	}
+
+	if !serverMode && term.IsTerminal(int(os.Stdin.Fd())) && confirmProject(path, commands) {
+		if err := trustProject(path, layer.digest); err != nil {
+			log.Error("Failed to save the trust of the project config", "path", path, "error", err)
+		}
+		return
+	}
+
+	log.Warn("Project config is not trusted, its servers and secrets are ignored",
+		"path", path,
+		"trust", "mcphost config trust")
+	for name, server := range layer.MCPServers {
+		if server.Config.GetOptions().Disabled {
+			continue
+		}
+		delete(layer.MCPServers, name)
+		if _, ok := c.sources["mcpServers."+name]; !ok {
+			c.sources["mcpServers."+name] = "ignored, " + path + " is not trusted"
+		}
+	}
+	if layer.Secrets != nil {
+		for name := range layer.Secrets.Commands {
+			c.sources["secrets.commands."+name] = "ignored, " + path + " is not trusted"
+		}
+		layer.Secrets = nil
+	}
+}
+
+func confirmProject(path string, commands []string) bool {
+	fmt.Printf("\n%s\n", approvalStyle.Render(fmt.Sprintf(
+		"%s %s\n\n%s",
+		toolNameStyle.Render("Project config:"), path,
+		"- "+strings.Join(commands, "\n- "),
+	)))
+
+	var trusted bool
+	err := huh.NewForm(huh.NewGroup(huh.NewConfirm().
+		Title("Trust this project config and use its servers and secrets?").
+		Affirmative("Trust").
+		Negative("Ignore them").
+		Value(&trusted)),
+	).WithWidth(getTerminalWidth()).
+		WithTheme(huh.ThemeCharm()).
+		Run()
+	if err != nil {
+		if !errors.Is(err, huh.ErrUserAborted) {
+			log.Error("Error asking to trust the project config", "error", err)
+		}
+		return false
+	}
+	return trusted
+}
+
+// configDigest identifies the content of a config file, so that a trusted
+// project config has to be trusted again once it changes
+func configDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// trustedProjectsPath is the file keeping the digests of the trusted
+// project configs by path, $HOME/.mcphost/trusted-projects.json
+func trustedProjectsPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".mcphost", "trusted-projects.json"), nil
+}
+
+func readTrustedProjects() (map[string]string, error) {
+	path, err := trustedProjectsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[string]string), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading trusted projects: %w", err)
+	}
+	trusted := make(map[string]string)
+	if err := json.Unmarshal(data, &trusted); err != nil {
+		return nil, fmt.Errorf("error reading trusted projects %s: %w", path, err)
+	}
+	return trusted, nil
+}
+
+func projectTrusted(path, digest string) bool {
+	trusted, err := readTrustedProjects()
+	if err != nil {
+		log.Warn("Failed to read the trusted projects", "error", err)
+		return false
+	}
+	return trusted[path] == digest
+}
+
+// trustProject records that the user trusts the project config at path
+// with the given digest
+func trustProject(path, digest string) error {
+	trusted, err := readTrustedProjects()
+	if err != nil {
+		return err
+	}
+	trusted[path] = digest
+
+	file, err := trustedProjectsPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(trusted, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return fmt.Errorf("error saving trusted projects: %w", err)
+	}
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		return fmt.Errorf("error saving trusted projects: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,430 @@
+// Package agent runs the loop between a model and the tools of MCP servers:
+// the model is asked for a response, the tools it calls are run and their
+// results are sent back to it until it answers without calling a tool.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/mark3labs/mcphost/pkg/history"
+	"github.com/mark3labs/mcphost/pkg/llm"
+)
+
+// Backoff of the retries while the model is overloaded
+const (
+	overloadedInitialBackoff = 1 * time.Second
+	overloadedMaxBackoff     = 30 * time.Second
+	overloadedMaxRetries     = 5
+)
+
+// Options configure an Agent. Only the provider is required.
+type Options struct {
+	Provider llm.Provider
+
+	// Clients are the MCP servers whose tools the model can call, by name
+	Clients map[string]mcpclient.MCPClient
+
+	// Tools returns the tools offered to the model. The tools of all
+	// clients are listed at the start of each run if it is nil.
+	Tools func() []llm.Tool
+
+	// Executor approves and runs the tool calls. Without one, every call
+	// runs on its client, one after another.
+	Executor Executor
+
+	// Stream asks for the responses of the model to be streamed, if the
+	// provider supports it
+	Stream bool
+
+	// History is the conversation so far
+	History []history.HistoryMessage
+
+	// MaxSteps is the most responses the model may give for one prompt.
+	// The tool calls of the last one aren't run and the run ends with
+	// ErrMaxSteps. There is no limit if it is 0.
+	MaxSteps int
+
+	// MaxRepeatedCalls is the number of times in a row the model may call
+	// a tool with the same arguments. The call that reaches it isn't run
+	// and the run ends with ErrRepeatedToolCall. Repeated calls are allowed
+	// if it is 0.
+	MaxRepeatedCalls int
+
+	// ContextTokens is the most tokens of the conversation and the tool
+	// definitions sent to the model. The oldest turns are left out of what
+	// is sent to fit, but stay in the history, and the turn of the latest
+	// prompt is always sent. There is no limit if it is 0.
+	ContextTokens int
+
+	// MaxMessages is the most messages sent to the model. Older ones are
+	// left out of what is sent, but stay in the history. There is no limit
+	// if it is 0.
+	MaxMessages int
+
+	// MaxToolResultTokens is the most tokens of the text of a tool result.
+	// Longer results are cut, with a note saying so. There is no limit if
+	// it is 0.
+	MaxToolResultTokens int
+}
+
+var (
+	// ErrMaxSteps ends a run when the model still calls tools after
+	// Options.MaxSteps responses
+	ErrMaxSteps = errors.New("too many steps")
+
+	// ErrRepeatedToolCall ends a run when the model repeats the same tool
+	// call Options.MaxRepeatedCalls times in a row
+	ErrRepeatedToolCall = errors.New("repeated tool call")
+)
+
+// Agent holds a conversation with a model that can call the tools of MCP
+// servers. An Agent runs one prompt at a time.
+type Agent struct {
+	provider llm.Provider
+	clients  map[string]mcpclient.MCPClient
+	tools    func() []llm.Tool
+	executor Executor
+	stream   bool
+	messages []history.HistoryMessage
+
+	maxSteps            int
+	maxRepeatedCalls    int
+	contextTokens       int
+	maxMessages         int
+	maxToolResultTokens int
+}
+
+// New creates an Agent
+func New(options Options) *Agent {
+	executor := options.Executor
+	if executor == nil {
+		executor = clientExecutor{clients: options.Clients}
+	}
+	return &Agent{
+		provider: options.Provider,
+		clients:  options.Clients,
+		tools:    options.Tools,
+		executor: executor,
+		stream:   options.Stream,
+		messages: options.History,
+
+		maxSteps:            options.MaxSteps,
+		maxRepeatedCalls:    options.MaxRepeatedCalls,
+		contextTokens:       options.ContextTokens,
+		maxMessages:         options.MaxMessages,
+		maxToolResultTokens: options.MaxToolResultTokens,
+	}
+}
+
+// History returns the whole conversation, including the prompts and
+// responses of runs that failed
+func (a *Agent) History() []history.HistoryMessage {
+	return a.messages
+}
+
+// Run sends a prompt to the model and runs the tools it calls until it
+// answers without calling one. It returns the text of that answer. The
+// progress of the run is sent to handle, which may be nil. An empty prompt
+// continues the conversation as it is.
+//
+// A run that is stopped by MaxSteps or MaxRepeatedCalls keeps the calls it
+// didn't run in the history, with the reason as their result, so that the
+// model learns why when the conversation goes on.
+func (a *Agent) Run(ctx context.Context, prompt string, handle func(Event)) (string, error) {
+	if handle == nil {
+		handle = func(Event) {}
+	}
+
+	if prompt != "" {
+		a.messages = append(a.messages, history.HistoryMessage{
+			Role: "user",
+			Content: []history.ContentBlock{{
+				Type: "text",
+				Text: prompt,
+			}},
+		})
+	}
+
+	tools, err := a.listTools(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var loop loopCheck
+	for steps := 1; ; steps++ {
+		message, err := a.respond(ctx, a.fitted(tools), tools, handle)
+		if err != nil {
+			return "", err
+		}
+
+		var content []history.ContentBlock
+		if message.GetContent() != "" {
+			content = append(content, history.ContentBlock{
+				Type: "text",
+				Text: message.GetContent(),
+			})
+		}
+		toolCalls := message.GetToolCalls()
+		for _, toolCall := range toolCalls {
+			input, _ := json.Marshal(toolCall.GetArguments())
+			content = append(content, history.ContentBlock{
+				Type:  "tool_use",
+				ID:    toolCall.GetID(),
+				Name:  toolCall.GetName(),
+				Input: input,
+			})
+		}
+		if len(content) > 0 {
+			a.messages = append(a.messages, history.HistoryMessage{
+				Role:    message.GetRole(),
+				Content: content,
+			})
+		}
+
+		if len(toolCalls) == 0 {
+			return message.GetContent(), nil
+		}
+
+		blocked := make([]string, len(toolCalls))
+		var stop error
+		if a.maxSteps > 0 && steps >= a.maxSteps {
+			for i := range blocked {
+				blocked[i] = fmt.Sprintf("Not run: the limit of %d steps for one prompt was reached.", a.maxSteps)
+			}
+			stop = fmt.Errorf("%w: the model was still calling tools after %d steps", ErrMaxSteps, steps)
+		}
+		for i, toolCall := range toolCalls {
+			repeats := loop.add(toolCall)
+			if a.maxRepeatedCalls == 0 || repeats < a.maxRepeatedCalls || blocked[i] != "" {
+				continue
+			}
+			blocked[i] = fmt.Sprintf(
+				"Not run: %s was called %d times in a row with the same arguments. Try a different approach or ask the user for help.",
+				toolCall.GetName(), repeats)
+			if stop == nil {
+				stop = fmt.Errorf("%w: the model called %s %d times in a row with the same arguments",
+					ErrRepeatedToolCall, toolCall.GetName(), repeats)
+			}
+		}
+
+		for _, result := range a.runTools(ctx, toolCalls, blocked, handle) {
+			a.messages = append(a.messages, history.HistoryMessage{
+				Role:    "tool",
+				Content: []history.ContentBlock{result},
+			})
+		}
+		if stop != nil {
+			return "", stop
+		}
+
+		// The results are kept so the model knows what happened, but it
+		// isn't asked about them
+		if ctx.Err() != nil {
+			return "", context.Cause(ctx)
+		}
+	}
+}
+
+func (a *Agent) listTools(ctx context.Context) ([]llm.Tool, error) {
+	if a.tools != nil {
+		return a.tools(), nil
+	}
+	return listTools(ctx, a.clients)
+}
+
+// respond sends messages to the model and asks for a response, retrying
+// while it is overloaded
+func (a *Agent) respond(
+	ctx context.Context,
+	messages []history.HistoryMessage,
+	tools []llm.Tool,
+	handle func(Event),
+) (llm.Message, error) {
+	// Messages already implement llm.Message
+	llmMessages := make([]llm.Message, len(messages))
+	for i := range messages {
+		llmMessages[i] = &messages[i]
+	}
+
+	streamer, streaming := a.provider.(llm.StreamingProvider)
+	streaming = streaming && a.stream
+
+	backoff := overloadedInitialBackoff
+	for retries := 0; ; retries++ {
+		handle(ThinkingEvent{})
+
+		var message llm.Message
+		var err error
+		if streaming {
+			message, err = streamer.StreamMessage(ctx, "", llmMessages, tools,
+				func(chunk llm.StreamChunk) {
+					if chunk.Text != "" {
+						handle(TextEvent{Text: chunk.Text})
+					}
+				})
+		} else {
+			message, err = a.provider.CreateMessage(ctx, "", llmMessages, tools)
+		}
+
+		if err == nil {
+			if !streaming && message.GetContent() != "" {
+				handle(TextEvent{Text: message.GetContent()})
+			}
+			handle(ResponseEvent{
+				Text:      message.GetContent(),
+				ToolCalls: len(message.GetToolCalls()),
+				Streamed:  streaming,
+			})
+			if inputTokens, outputTokens := message.GetUsage(); inputTokens > 0 || outputTokens > 0 {
+				handle(UsageEvent{InputTokens: inputTokens, OutputTokens: outputTokens})
+			}
+			return message, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+		if !strings.Contains(err.Error(), "overloaded_error") {
+			handle(ErrorEvent{Err: err})
+			return nil, err
+		}
+		if retries >= overloadedMaxRetries {
+			err = errors.New("the model is overloaded, please wait a few minutes and try again")
+			handle(ErrorEvent{Err: err})
+			return nil, err
+		}
+
+		handle(ErrorEvent{Err: err, Retry: true, Backoff: backoff})
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		}
+		backoff *= 2
+		if backoff > overloadedMaxBackoff {
+			backoff = overloadedMaxBackoff
+		}
+	}
+}
+
+// runTools approves the tool calls of a response one at a time, runs the
+// approved ones and returns the results in the order of the calls. Calls
+// with a reason in blocked aren't run and get the reason as their result.
+func (a *Agent) runTools(
+	ctx context.Context,
+	toolCalls []llm.ToolCall,
+	blocked []string,
+	handle func(Event),
+) []history.ContentBlock {
+	calls := make([]*ToolCall, len(toolCalls))
+	denied := make([]string, len(toolCalls))
+	var approved []*ToolCall
+
+	for i, toolCall := range toolCalls {
+		call := &ToolCall{
+			ID:        toolCall.GetID(),
+			Name:      toolCall.GetName(),
+			Arguments: toolCall.GetArguments(),
+		}
+		calls[i] = call
+		handle(ToolStartEvent{ID: call.ID, Name: call.Name, Arguments: call.Arguments})
+
+		if blocked[i] != "" {
+			denied[i] = blocked[i]
+			continue
+		}
+
+		var ok bool
+		call.Server, call.Tool, ok = SplitToolName(call.Name)
+		if !ok {
+			denied[i] = fmt.Sprintf("Invalid tool name format: %s", call.Name)
+			continue
+		}
+		if call.Arguments == nil {
+			call.Arguments = make(map[string]interface{})
+		}
+
+		if ok, reason := a.executor.Approve(ctx, call); !ok {
+			denied[i] = reason
+			continue
+		}
+		approved = append(approved, call)
+	}
+
+	if len(approved) > 0 {
+		a.executor.Run(ctx, approved)
+	}
+
+	results := make([]history.ContentBlock, len(calls))
+	for i, call := range calls {
+		var text string
+		var content []mcp.Content
+		isError := true
+
+		switch {
+		case denied[i] != "":
+			text = denied[i]
+			content = []mcp.Content{mcp.NewTextContent(text)}
+		case call.Err != nil:
+			text = fmt.Sprintf("Error calling tool %s: %v", call.Tool, call.Err)
+			content = []mcp.Content{mcp.NewTextContent(text)}
+		case call.Result == nil:
+			text = fmt.Sprintf("Tool %s returned no result", call.Tool)
+			content = []mcp.Content{mcp.NewTextContent(text)}
+		default:
+			content = call.Result.Content
+			isError = call.Result.IsError
+			var texts []string
+			for _, item := range content {
+				if textContent, ok := item.(mcp.TextContent); ok {
+					texts = append(texts, textContent.Text)
+				}
+			}
+			text, content = a.truncate(strings.TrimSpace(strings.Join(texts, " ")), content)
+		}
+
+		results[i] = history.ContentBlock{
+			Type:      "tool_result",
+			ToolUseID: call.ID,
+			Text:      text,
+			Content:   content,
+		}
+		log.Debug("created tool result block",
+			"block", results[i],
+			"tool_id", call.ID)
+
+		handle(ToolEndEvent{ID: call.ID, Name: call.Name, Result: text, IsError: isError})
+	}
+	return results
+}
+
+// loopCheck counts how many times in a row the model made the same tool
+// call
+type loopCheck struct {
+	last    string
+	repeats int
+}
+
+// add records a tool call and returns the number of times in a row it was
+// made
+func (l *loopCheck) add(toolCall llm.ToolCall) int {
+	// Maps are marshaled with sorted keys, so the same arguments give the
+	// same key
+	arguments, _ := json.Marshal(toolCall.GetArguments())
+	key := toolCall.GetName() + string(arguments)
+	if key == l.last {
+		l.repeats++
+	} else {
+		l.last, l.repeats = key, 1
+	}
+	return l.repeats
+}
@@ -0,0 +1,247 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/mark3labs/mcphost/pkg/history"
+	"github.com/mark3labs/mcphost/pkg/llm"
+)
+
+// scriptedProvider answers with the responses of a script, one per call
+type scriptedProvider struct {
+	respond func(step int) *history.HistoryMessage
+	steps   int
+}
+
+func (p *scriptedProvider) CreateMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (llm.Message, error) {
+	p.steps++
+	return p.respond(p.steps), nil
+}
+
+func (p *scriptedProvider) CreateToolResponse(toolCallID string, content interface{}) (llm.Message, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *scriptedProvider) SupportsTools() bool { return true }
+
+func (p *scriptedProvider) Name() string { return "scripted" }
+
+// toolUse returns a response that calls a tool with arguments
+func toolUse(id, name string, arguments map[string]interface{}) *history.HistoryMessage {
+	input, _ := json.Marshal(arguments)
+	return &history.HistoryMessage{
+		Role: "assistant",
+		Content: []history.ContentBlock{{
+			Type:  "tool_use",
+			ID:    id,
+			Name:  name,
+			Input: input,
+		}},
+	}
+}
+
+// recordingExecutor approves every call and answers it with "ok"
+type recordingExecutor struct {
+	ran []string
+}
+
+func (e *recordingExecutor) Approve(ctx context.Context, call *ToolCall) (bool, string) {
+	return true, ""
+}
+
+func (e *recordingExecutor) Run(ctx context.Context, calls []*ToolCall) {
+	for _, call := range calls {
+		e.ran = append(e.ran, call.ID)
+		call.Result = mcp.NewToolResultText("ok")
+	}
+}
+
+type testToolCall struct {
+	name      string
+	arguments map[string]interface{}
+}
+
+func (c testToolCall) GetName() string                      { return c.name }
+func (c testToolCall) GetArguments() map[string]interface{} { return c.arguments }
+func (c testToolCall) GetID() string                        { return "" }
+
+func TestLoopCheckAdd(t *testing.T) {
+	tests := []struct {
+		name  string
+		calls []testToolCall
+		want  []int
+	}{
+		{
+			name: "same call",
+			calls: []testToolCall{
+				{"fs__read", map[string]interface{}{"path": "a"}},
+				{"fs__read", map[string]interface{}{"path": "a"}},
+				{"fs__read", map[string]interface{}{"path": "a"}},
+			},
+			want: []int{1, 2, 3},
+		},
+		{
+			name: "other arguments",
+			calls: []testToolCall{
+				{"fs__read", map[string]interface{}{"path": "a"}},
+				{"fs__read", map[string]interface{}{"path": "b"}},
+				{"fs__read", map[string]interface{}{"path": "b"}},
+			},
+			want: []int{1, 1, 2},
+		},
+		{
+			name: "other tool",
+			calls: []testToolCall{
+				{"fs__read", map[string]interface{}{"path": "a"}},
+				{"fs__stat", map[string]interface{}{"path": "a"}},
+				{"fs__read", map[string]interface{}{"path": "a"}},
+			},
+			want: []int{1, 1, 1},
+		},
+		{
+			name: "argument order",
+			calls: []testToolCall{
+				{"fs__copy", map[string]interface{}{"from": "a", "to": "b"}},
+				{"fs__copy", map[string]interface{}{"to": "b", "from": "a"}},
+			},
+			want: []int{1, 2},
+		},
+		{
+			name: "no arguments",
+			calls: []testToolCall{
+				{"clock__now", nil},
+				{"clock__now", nil},
+			},
+			want: []int{1, 2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var loop loopCheck
+			for i, call := range test.calls {
+				if got := loop.add(call); got != test.want[i] {
+					t.Errorf("call %d: add() = %d, want %d", i, got, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRunLimits(t *testing.T) {
+	sameCall := func(step int) *history.HistoryMessage {
+		return toolUse(fmt.Sprintf("call-%d", step), "fs__read", map[string]interface{}{"path": "a"})
+	}
+	newCall := func(step int) *history.HistoryMessage {
+		return toolUse(fmt.Sprintf("call-%d", step), "fs__read", map[string]interface{}{"path": step})
+	}
+
+	tests := []struct {
+		name             string
+		respond          func(step int) *history.HistoryMessage
+		maxSteps         int
+		maxRepeatedCalls int
+		wantErr          error
+		wantRan          int
+		wantResult       string
+	}{
+		{
+			name: "answer",
+			respond: func(step int) *history.HistoryMessage {
+				if step < 3 {
+					return newCall(step)
+				}
+				return &history.HistoryMessage{
+					Role:    "assistant",
+					Content: []history.ContentBlock{{Type: "text", Text: "done"}},
+				}
+			},
+			maxSteps:         3,
+			maxRepeatedCalls: 2,
+			wantRan:          2,
+		},
+		{
+			name:       "max steps",
+			respond:    newCall,
+			maxSteps:   3,
+			wantErr:    ErrMaxSteps,
+			wantRan:    2,
+			wantResult: "Not run: the limit of 3 steps",
+		},
+		{
+			name:             "repeated call",
+			respond:          sameCall,
+			maxSteps:         10,
+			maxRepeatedCalls: 3,
+			wantErr:          ErrRepeatedToolCall,
+			wantRan:          2,
+			wantResult:       "Not run: fs__read was called 3 times in a row",
+		},
+		{
+			name:             "max steps before repeated call",
+			respond:          sameCall,
+			maxSteps:         2,
+			maxRepeatedCalls: 2,
+			wantErr:          ErrMaxSteps,
+			wantRan:          1,
+			wantResult:       "Not run: the limit of 2 steps",
+		},
+		{
+			name:       "repeated calls allowed",
+			respond:    sameCall,
+			maxSteps:   5,
+			wantErr:    ErrMaxSteps,
+			wantRan:    4,
+			wantResult: "Not run: the limit of 5 steps",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			executor := &recordingExecutor{}
+			a := New(Options{
+				Provider:         &scriptedProvider{respond: test.respond},
+				Tools:            func() []llm.Tool { return nil },
+				Executor:         executor,
+				MaxSteps:         test.maxSteps,
+				MaxRepeatedCalls: test.maxRepeatedCalls,
+			})
+
+			text, err := a.Run(context.Background(), "read a", nil)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Run() error = %v, want %v", err, test.wantErr)
+			}
+			if len(executor.ran) != test.wantRan {
+				t.Errorf("ran %d calls, want %d", len(executor.ran), test.wantRan)
+			}
+			if test.wantErr == nil {
+				if text != "done" {
+					t.Errorf("Run() = %q, want %q", text, "done")
+				}
+				return
+			}
+
+			// The call that wasn't run is answered in the history
+			messages := a.History()
+			last := messages[len(messages)-1]
+			if last.Role != "tool" || len(last.Content) != 1 {
+				t.Fatalf("last message = %+v, want a tool result", last)
+			}
+			if result := last.Content[0].Text; !strings.HasPrefix(result, test.wantResult) {
+				t.Errorf("result = %q, want it to start with %q", result, test.wantResult)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/log"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/mark3labs/mcphost/pkg/history"
+	"github.com/mark3labs/mcphost/pkg/llm"
+)
+
+// messageOverhead is the tokens a message takes besides its content, for
+// its role and framing
+const messageOverhead = 4
+
+// fitted returns the part of the conversation that is sent to the model:
+// the last MaxMessages messages, of which the oldest turns are left out
+// until the rest fits in ContextTokens with the tools. A turn starts with
+// the messages of the user, and the latest turn is always sent, so tool
+// calls are never split from their results. The history itself is kept
+// whole.
+func (a *Agent) fitted(tools []llm.Tool) []history.HistoryMessage {
+	messages := a.messages
+	if a.maxMessages > 0 {
+		messages = lastMessages(messages, a.maxMessages)
+	}
+	if a.contextTokens <= 0 {
+		return messages
+	}
+
+	budget := a.contextTokens
+	if data, err := json.Marshal(tools); err == nil {
+		budget -= a.countTokens(string(data))
+	}
+
+	tokens := 0
+	var turns []int
+	for i, message := range messages {
+		tokens += a.messageTokens(message)
+		if i == 0 || message.Role == "user" && messages[i-1].Role != "user" {
+			turns = append(turns, i)
+		}
+	}
+
+	cut := 0
+	for i := 1; i < len(turns) && tokens > budget; i++ {
+		for _, message := range messages[cut:turns[i]] {
+			tokens -= a.messageTokens(message)
+		}
+		cut = turns[i]
+	}
+	if cut > 0 {
+		log.Debug("left old messages out to fit the context",
+			"messages", cut,
+			"tokens", tokens,
+			"budget", budget)
+	}
+	return messages[cut:]
+}
+
+// messageTokens returns the tokens a message takes
+func (a *Agent) messageTokens(message history.HistoryMessage) int {
+	tokens := messageOverhead
+	for _, block := range message.Content {
+		switch block.Type {
+		case "tool_use":
+			tokens += a.countTokens(block.Name) + a.countTokens(string(block.Input))
+		case "tool_result":
+			if block.Text != "" || block.Content == nil {
+				tokens += a.countTokens(block.Text)
+			} else if data, err := json.Marshal(block.Content); err == nil {
+				tokens += a.countTokens(string(data))
+			}
+		default:
+			tokens += a.countTokens(block.Text)
+		}
+	}
+	return tokens
+}
+
+func (a *Agent) countTokens(text string) int {
+	return llm.CountTokens(a.provider, text)
+}
+
+// truncate cuts the text of a tool result that is longer than
+// MaxToolResultTokens, and says so at its end. The other content of the
+// result is kept.
+func (a *Agent) truncate(text string, content []mcp.Content) (string, []mcp.Content) {
+	tokens := a.countTokens(text)
+	if a.maxToolResultTokens <= 0 || tokens <= a.maxToolResultTokens {
+		return text, content
+	}
+
+	keep := len(text) * a.maxToolResultTokens / tokens
+	for keep > 0 && !utf8.RuneStart(text[keep]) {
+		keep--
+	}
+	text = text[:keep] + fmt.Sprintf(
+		"\n\n[Truncated: the result was about %d tokens long and only the first %d are shown.]",
+		tokens, a.maxToolResultTokens)
+
+	truncated := []mcp.Content{mcp.NewTextContent(text)}
+	for _, item := range content {
+		if _, ok := item.(mcp.TextContent); !ok {
+			truncated = append(truncated, item)
+		}
+	}
+	return text, truncated
+}
+
+// lastMessages returns the last count messages, without the tool calls
+// whose results were left out and the results whose calls were
+func lastMessages(messages []history.HistoryMessage, count int) []history.HistoryMessage {
+	if len(messages) <= count {
+		return messages
+	}
+
+	// Keep only the most recent messages based on window size
+	messages = messages[len(messages)-count:]
+
+	// Handle messages
+	toolUseIds := make(map[string]bool)
+	toolResultIds := make(map[string]bool)
+
+	// First pass: collect all tool use and result IDs
+	for _, msg := range messages {
+		for _, block := range msg.Content {
+			if block.Type == "tool_use" {
+				toolUseIds[block.ID] = true
+			} else if block.Type == "tool_result" {
+				toolResultIds[block.ToolUseID] = true
+			}
+		}
+	}
+
+	// Second pass: filter out orphaned tool calls/results
+	var prunedMessages []history.HistoryMessage
+	for _, msg := range messages {
+		var prunedBlocks []history.ContentBlock
+		for _, block := range msg.Content {
+			keep := true
+			if block.Type == "tool_use" {
+				keep = toolResultIds[block.ID]
+			} else if block.Type == "tool_result" {
+				keep = toolUseIds[block.ToolUseID]
+			}
+			if keep {
+				prunedBlocks = append(prunedBlocks, block)
+			}
+		}
+		// Only include messages that have content or are not assistant messages
+		if (len(prunedBlocks) > 0 && msg.Role == "assistant") ||
+			msg.Role != "assistant" {
+			hasTextBlock := false
+			for _, block := range msg.Content {
+				if block.Type == "text" {
+					hasTextBlock = true
+					break
+				}
+			}
+			if len(prunedBlocks) > 0 || hasTextBlock {
+				msg.Content = prunedBlocks
+				prunedMessages = append(prunedMessages, msg)
+			}
+		}
+	}
+	return prunedMessages
+}
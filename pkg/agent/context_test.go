@@ -0,0 +1,209 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/mark3labs/mcphost/pkg/history"
+)
+
+// textMessage returns a message of 105 tokens: 400 bytes of text at four
+// bytes per token, plus one, plus the overhead
+func textMessage(role string) history.HistoryMessage {
+	return history.HistoryMessage{
+		Role:    role,
+		Content: []history.ContentBlock{{Type: "text", Text: strings.Repeat("a", 400)}},
+	}
+}
+
+func TestFitted(t *testing.T) {
+	// Turns start at 0, 2 and 6. The tool call at 3 and its result at 4
+	// are in the same turn.
+	messages := []history.HistoryMessage{
+		textMessage("user"),
+		textMessage("assistant"),
+		textMessage("user"),
+		textMessage("assistant"),
+		textMessage("tool"),
+		textMessage("assistant"),
+		textMessage("user"),
+		textMessage("user"),
+	}
+
+	tests := []struct {
+		name          string
+		contextTokens int
+		wantFirst     int
+	}{
+		{name: "no limit", contextTokens: 0, wantFirst: 0},
+		{name: "fits", contextTokens: 1000, wantFirst: 0},
+		{name: "exactly fits", contextTokens: 8*105 + 2, wantFirst: 0},
+		{name: "first turn left out", contextTokens: 8 * 105, wantFirst: 2},
+		{name: "two turns left out", contextTokens: 400, wantFirst: 6},
+		{name: "latest turn kept", contextTokens: 50, wantFirst: 6},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := New(Options{
+				History:       append([]history.HistoryMessage(nil), messages...),
+				ContextTokens: test.contextTokens,
+			})
+
+			got := a.fitted(nil)
+			if first := len(messages) - len(got); first != test.wantFirst {
+				t.Errorf("fitted() starts at message %d, want %d", first, test.wantFirst)
+			}
+			if len(a.History()) != len(messages) {
+				t.Errorf("history has %d messages, want %d", len(a.History()), len(messages))
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	image := mcp.NewImageContent("data", "image/png")
+
+	tests := []struct {
+		name                string
+		maxToolResultTokens int
+		text                string
+		wantText            string
+		wantTruncated       bool
+	}{
+		{
+			name:                "no limit",
+			maxToolResultTokens: 0,
+			text:                strings.Repeat("a", 400),
+			wantText:            strings.Repeat("a", 400),
+		},
+		{
+			name:                "under the limit",
+			maxToolResultTokens: 20,
+			text:                strings.Repeat("a", 40),
+			wantText:            strings.Repeat("a", 40),
+		},
+		{
+			name:                "ascii",
+			maxToolResultTokens: 10,
+			text:                strings.Repeat("a", 400),
+			wantText:            strings.Repeat("a", 39),
+			wantTruncated:       true,
+		},
+		{
+			// Byte 39 is in the middle of a rune, so the cut moves back to
+			// its start
+			name:                "utf-8",
+			maxToolResultTokens: 10,
+			text:                strings.Repeat("é", 200),
+			wantText:            strings.Repeat("é", 19),
+			wantTruncated:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := New(Options{MaxToolResultTokens: test.maxToolResultTokens})
+			content := []mcp.Content{mcp.NewTextContent(test.text), image}
+
+			text, truncated := a.truncate(test.text, content)
+			if !utf8.ValidString(text) {
+				t.Errorf("truncate() = %q, which isn't valid UTF-8", text)
+			}
+			if !test.wantTruncated {
+				if text != test.wantText || len(truncated) != len(content) {
+					t.Errorf("truncate() = %q with %d items, want it unchanged", text, len(truncated))
+				}
+				return
+			}
+
+			kept, note, ok := strings.Cut(text, "\n\n[Truncated:")
+			if !ok {
+				t.Fatalf("truncate() = %q, want a note at its end", text)
+			}
+			if kept != test.wantText {
+				t.Errorf("truncate() kept %q, want %q", kept, test.wantText)
+			}
+			if !strings.Contains(note, "about 101 tokens long and only the first 10 are shown") {
+				t.Errorf("note = %q", note)
+			}
+			if len(truncated) != 2 || truncated[1] != image {
+				t.Fatalf("content = %v, want the text and the image", truncated)
+			}
+			if textContent, ok := truncated[0].(mcp.TextContent); !ok || textContent.Text != text {
+				t.Errorf("content text = %v, want %q", truncated[0], text)
+			}
+		})
+	}
+}
+
+func TestLastMessages(t *testing.T) {
+	toolUse := func(id string) history.HistoryMessage {
+		return history.HistoryMessage{
+			Role:    "assistant",
+			Content: []history.ContentBlock{{Type: "tool_use", ID: id, Name: "fs__read"}},
+		}
+	}
+	toolResult := func(id string) history.HistoryMessage {
+		return history.HistoryMessage{
+			Role:    "tool",
+			Content: []history.ContentBlock{{Type: "tool_result", ToolUseID: id, Text: "ok"}},
+		}
+	}
+	messages := []history.HistoryMessage{
+		textMessage("user"),
+		toolUse("1"),
+		toolResult("1"),
+		toolUse("2"),
+		toolResult("2"),
+		textMessage("user"),
+	}
+
+	tests := []struct {
+		name      string
+		messages  []history.HistoryMessage
+		count     int
+		wantRoles []string
+	}{
+		{
+			name:      "all",
+			messages:  messages,
+			count:     6,
+			wantRoles: []string{"user", "assistant", "tool", "assistant", "tool", "user"},
+		},
+		{
+			name:      "call and result",
+			messages:  messages,
+			count:     3,
+			wantRoles: []string{"assistant", "tool", "user"},
+		},
+		{
+			name:      "result without its call",
+			messages:  messages,
+			count:     4,
+			wantRoles: []string{"assistant", "tool", "user"},
+		},
+		{
+			name:      "call without its result",
+			messages:  messages[:4],
+			count:     3,
+			wantRoles: []string{"assistant", "tool"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := lastMessages(test.messages, test.count)
+			roles := make([]string, len(got))
+			for i, message := range got {
+				roles[i] = message.Role
+			}
+			if strings.Join(roles, " ") != strings.Join(test.wantRoles, " ") {
+				t.Errorf("lastMessages() roles = %v, want %v", roles, test.wantRoles)
+			}
+		})
+	}
+}
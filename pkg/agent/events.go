@@ -0,0 +1,70 @@
+package agent
+
+import "time"
+
+// Event reports the progress of a run to its caller. It is one of the event
+// types below.
+type Event interface {
+	event()
+}
+
+// ThinkingEvent is sent when the model is asked for a response
+type ThinkingEvent struct{}
+
+// TextEvent carries text of the model. It is a piece of the response when
+// the response is streamed, and the whole text otherwise.
+type TextEvent struct {
+	Text string
+}
+
+// ResponseEvent is sent once a response of the model is complete
+type ResponseEvent struct {
+	// Text is the whole text of the response
+	Text string
+
+	// ToolCalls is the number of tools the model called in the response
+	ToolCalls int
+
+	// Streamed is set if the text was sent in pieces as it was generated
+	Streamed bool
+}
+
+// UsageEvent reports the tokens used by one response of the model
+type UsageEvent struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// ToolStartEvent is sent when the model calls a tool, before the call is
+// approved
+type ToolStartEvent struct {
+	ID        string
+	Name      string
+	Arguments map[string]interface{}
+}
+
+// ToolEndEvent carries the result of a tool call. Calls that were denied or
+// failed end with IsError set and the reason as their result.
+type ToolEndEvent struct {
+	ID      string
+	Name    string
+	Result  string
+	IsError bool
+}
+
+// ErrorEvent is sent when the model fails to respond. The run ends with
+// the error unless Retry is set, in which case the model is asked again
+// after Backoff.
+type ErrorEvent struct {
+	Err     error
+	Retry   bool
+	Backoff time.Duration
+}
+
+func (ThinkingEvent) event()  {}
+func (TextEvent) event()      {}
+func (ResponseEvent) event()  {}
+func (UsageEvent) event()     {}
+func (ToolStartEvent) event() {}
+func (ToolEndEvent) event()   {}
+func (ErrorEvent) event()     {}
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/mark3labs/mcphost/pkg/llm"
+)
+
+// toolNameSeparator joins the name of a server and the name of one of its
+// tools into the name the model sees
+const toolNameSeparator = "__"
+
+// ToolName returns the name the model sees for a tool of a server
+func ToolName(serverName, toolName string) string {
+	return serverName + toolNameSeparator + toolName
+}
+
+// SplitToolName splits the name of a tool the model called into the names
+// of the server and the tool
+func SplitToolName(name string) (serverName, toolName string, ok bool) {
+	parts := strings.Split(name, toolNameSeparator)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// ServerTools converts the tools of an MCP server into tools for the model
+func ServerTools(serverName string, mcpTools []mcp.Tool) []llm.Tool {
+	tools := make([]llm.Tool, len(mcpTools))
+	for i, tool := range mcpTools {
+		tools[i] = llm.Tool{
+			Name:        ToolName(serverName, tool.Name),
+			Description: tool.Description,
+			InputSchema: llm.Schema{
+				Type:       tool.InputSchema.Type,
+				Properties: tool.InputSchema.Properties,
+				Required:   tool.InputSchema.Required,
+			},
+		}
+	}
+	return tools
+}
+
+// ToolCall is a call of a tool by the model
+type ToolCall struct {
+	ID        string
+	Name      string
+	Server    string
+	Tool      string
+	Arguments map[string]interface{}
+
+	// Result is set once the call ran, or Err if it failed
+	Result *mcp.CallToolResult
+	Err    error
+}
+
+// Executor decides which tool calls may run, and runs them
+type Executor interface {
+	// Approve reports whether a call may run. If it may not, reason is
+	// sent to the model as the result of the call.
+	Approve(ctx context.Context, call *ToolCall) (ok bool, reason string)
+
+	// Run runs the approved calls of one response and sets their Result
+	// or Err
+	Run(ctx context.Context, calls []*ToolCall)
+}
+
+// clientExecutor runs every call, one after another, on the client of its
+// server. It is the Executor of agents that aren't given one.
+type clientExecutor struct {
+	clients map[string]mcpclient.MCPClient
+}
+
+func (e clientExecutor) Approve(ctx context.Context, call *ToolCall) (bool, string) {
+	if _, ok := e.clients[call.Server]; !ok {
+		return false, fmt.Sprintf("Server not found: %s", call.Server)
+	}
+	return true, ""
+}
+
+func (e clientExecutor) Run(ctx context.Context, calls []*ToolCall) {
+	for _, call := range calls {
+		request := mcp.CallToolRequest{}
+		request.Params.Name = call.Tool
+		request.Params.Arguments = call.Arguments
+		call.Result, call.Err = e.clients[call.Server].CallTool(ctx, request)
+	}
+}
+
+// listTools lists the tools of all clients
+func listTools(ctx context.Context, clients map[string]mcpclient.MCPClient) ([]llm.Tool, error) {
+	var tools []llm.Tool
+	for serverName, client := range clients {
+		result, err := client.ListTools(ctx, mcp.ListToolsRequest{})
+		if err != nil {
+			return nil, fmt.Errorf("error listing tools of %s: %w", serverName, err)
+		}
+		tools = append(tools, ServerTools(serverName, result.Tools)...)
+	}
+	return tools, nil
+}
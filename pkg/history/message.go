@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"strings"
 
+	"github.com/mark3labs/mcp-go/mcp"
+
 	"github.com/mark3labs/mcphost/pkg/llm"
 )
 
@@ -97,3 +99,42 @@ type ContentBlock struct {
 	Input     json.RawMessage `json:"input,omitempty"`
 	Content   interface{}     `json:"content,omitempty"`
 }
+
+// UnmarshalJSON restores tool result content as []mcp.Content, the type it
+// has when it comes straight from an MCP server, so that saved conversations
+// behave the same as live ones once loaded.
+func (b *ContentBlock) UnmarshalJSON(data []byte) error {
+	type contentBlock ContentBlock
+	var raw struct {
+		contentBlock
+		Content json.RawMessage `json:"content,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*b = ContentBlock(raw.contentBlock)
+	if len(raw.Content) == 0 {
+		return nil
+	}
+
+	if b.Type == "tool_result" {
+		var items []map[string]any
+		if err := json.Unmarshal(raw.Content, &items); err == nil {
+			content := make([]mcp.Content, 0, len(items))
+			for _, item := range items {
+				c, err := mcp.ParseContent(item)
+				if err != nil {
+					break
+				}
+				content = append(content, c)
+			}
+			if len(content) == len(items) {
+				b.Content = content
+				return nil
+			}
+		}
+	}
+
+	return json.Unmarshal(raw.Content, &b.Content)
+}
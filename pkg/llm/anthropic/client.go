@@ -1,10 +1,12 @@
 package anthropic
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 )
@@ -29,6 +31,67 @@ func NewClient(apiKey string, baseURL string) *Client {
 }
 
 func (c *Client) CreateMessage(ctx context.Context, req CreateRequest) (*APIMessage, error) {
+	resp, err := c.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var message APIMessage
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &message, nil
+}
+
+// StreamMessage sends a streaming request and calls fn for every event the
+// API sends until the stream ends.
+func (c *Client) StreamMessage(ctx context.Context, req CreateRequest, fn func(StreamEvent) error) error {
+	req.Stream = true
+
+	resp, err := c.post(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("error reading stream: %w", err)
+		}
+
+		// Every event carries its type in the data payload as well, so the
+		// "event:" lines can be skipped.
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:")
+		if !ok {
+			continue
+		}
+
+		var event StreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return fmt.Errorf("error decoding stream event: %w", err)
+		}
+
+		if event.Type == "error" && event.Error != nil {
+			return fmt.Errorf("%s: %s", event.Error.Type, event.Error.Message)
+		}
+
+		if err := fn(event); err != nil {
+			return err
+		}
+		if event.Type == "message_stop" {
+			return nil
+		}
+	}
+}
+
+func (c *Client) post(ctx context.Context, req CreateRequest) (*http.Response, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling request: %w", err)
@@ -47,9 +110,10 @@ func (c *Client) CreateMessage(ctx context.Context, req CreateRequest) (*APIMess
 	if err != nil {
 		return nil, fmt.Errorf("error making request: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
 		var errResp struct {
 			Error struct {
 				Type    string `json:"type"`
@@ -67,10 +131,5 @@ func (c *Client) CreateMessage(ctx context.Context, req CreateRequest) (*APIMess
 		return nil, fmt.Errorf("%s: %s", errResp.Error.Type, errResp.Error.Message)
 	}
 
-	var message APIMessage
-	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
-	}
-
-	return &message, nil
+	return resp, nil
 }
@@ -15,9 +15,10 @@ type Provider struct {
 	client       *Client
 	model        string
 	systemPrompt string
+	params       llm.GenerationParams
 }
 
-func NewProvider(apiKey, baseURL, model, systemPrompt string) *Provider {
+func NewProvider(apiKey, baseURL, model, systemPrompt string, params llm.GenerationParams) *Provider {
 	if model == "" {
 		model = "claude-3-5-sonnet-20240620" // 默认模型
 	}
@@ -25,6 +26,7 @@ func NewProvider(apiKey, baseURL, model, systemPrompt string) *Provider {
 		client:       NewClient(apiKey, baseURL),
 		model:        model,
 		systemPrompt: systemPrompt,
+		params:       params,
 	}
 }
 
@@ -39,6 +41,102 @@ func (p *Provider) CreateMessage(
 		"num_messages", len(messages),
 		"num_tools", len(tools))
 
+	// Make the API call
+	resp, err := p.client.CreateMessage(ctx, p.createRequest(prompt, messages, tools))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{Msg: *resp}, nil
+}
+
+func (p *Provider) StreamMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+	onChunk func(llm.StreamChunk),
+) (llm.Message, error) {
+	log.Debug("streaming message",
+		"prompt", prompt,
+		"num_messages", len(messages),
+		"num_tools", len(tools))
+
+	var msg APIMessage
+	// Tool inputs arrive as fragments of JSON, keyed by content block index
+	toolInputs := make(map[int]*strings.Builder)
+
+	err := p.client.StreamMessage(ctx, p.createRequest(prompt, messages, tools), func(event StreamEvent) error {
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				msg = *event.Message
+			}
+		case "content_block_start":
+			if event.ContentBlock == nil {
+				return nil
+			}
+			block := *event.ContentBlock
+			if block.Type == "tool_use" {
+				block.Input = nil
+				toolInputs[event.Index] = &strings.Builder{}
+				onChunk(llm.StreamChunk{ToolCallID: block.ID, ToolName: block.Name})
+			}
+			for len(msg.Content) <= event.Index {
+				msg.Content = append(msg.Content, ContentBlock{})
+			}
+			msg.Content[event.Index] = block
+		case "content_block_delta":
+			if event.Delta == nil || event.Index >= len(msg.Content) {
+				return nil
+			}
+			block := &msg.Content[event.Index]
+			switch event.Delta.Type {
+			case "text_delta":
+				block.Text += event.Delta.Text
+				onChunk(llm.StreamChunk{Text: event.Delta.Text})
+			case "input_json_delta":
+				if input, ok := toolInputs[event.Index]; ok {
+					input.WriteString(event.Delta.PartialJSON)
+				}
+				onChunk(llm.StreamChunk{
+					ToolCallID:    block.ID,
+					ToolName:      block.Name,
+					ToolArguments: event.Delta.PartialJSON,
+				})
+			}
+		case "content_block_stop":
+			if input, ok := toolInputs[event.Index]; ok && event.Index < len(msg.Content) {
+				raw := input.String()
+				if raw == "" {
+					raw = "{}"
+				}
+				msg.Content[event.Index].Input = json.RawMessage(raw)
+			}
+		case "message_delta":
+			if event.Delta != nil && event.Delta.StopReason != nil {
+				msg.StopReason = event.Delta.StopReason
+			}
+			if event.Usage != nil {
+				msg.Usage.OutputTokens = event.Usage.OutputTokens
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{Msg: msg}, nil
+}
+
+// createRequest converts the conversation and tools into a request for the
+// messages API
+func (p *Provider) createRequest(
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) CreateRequest {
 	anthropicMessages := make([]MessageParam, 0, len(messages))
 
 	for _, msg := range messages {
@@ -131,19 +229,20 @@ func (p *Provider) CreateMessage(
 		"messages", anthropicMessages,
 		"num_tools", len(tools))
 
-	// Make the API call
-	resp, err := p.client.CreateMessage(ctx, CreateRequest{
-		Model:     p.model,
-		Messages:  anthropicMessages,
-		MaxTokens: 4096,
-		Tools:     anthropicTools,
-		System:    p.systemPrompt,
-	})
-	if err != nil {
-		return nil, err
+	maxTokens := 4096
+	if p.params.MaxTokens > 0 {
+		maxTokens = p.params.MaxTokens
 	}
 
-	return &Message{Msg: *resp}, nil
+	return CreateRequest{
+		Model:       p.model,
+		Messages:    anthropicMessages,
+		MaxTokens:   maxTokens,
+		Temperature: p.params.Temperature,
+		TopP:        p.params.TopP,
+		Tools:       anthropicTools,
+		System:      p.systemPrompt,
+	}
 }
 
 func (p *Provider) SupportsTools() bool {
@@ -154,6 +253,12 @@ func (p *Provider) Name() string {
 	return "anthropic"
 }
 
+// CountTokens estimates the tokens of text. Claude models take a little
+// more tokens for the same text than the four bytes per token of others.
+func (p *Provider) CountTokens(text string) int {
+	return llm.EstimateTokens(text, 3.5)
+}
+
 func (p *Provider) CreateToolResponse(
 	toolCallID string,
 	content interface{},
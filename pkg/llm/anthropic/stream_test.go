@@ -0,0 +1,141 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcphost/pkg/llm"
+)
+
+// streamServer answers every request with the given server-sent events
+func streamServer(t *testing.T, events ...string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req CreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream {
+			t.Errorf("request isn't a streaming request: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			var typed struct {
+				Type string `json:"type"`
+			}
+			_ = json.Unmarshal([]byte(event), &typed)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typed.Type, event)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestStreamMessage(t *testing.T) {
+	messageStart := `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude","usage":{"input_tokens":12,"output_tokens":1}}}`
+	messageStop := `{"type":"message_stop"}`
+
+	tests := []struct {
+		name       string
+		events     []string
+		wantText   string
+		wantChunks string
+		wantCalls  []string
+		wantUsage  [2]int
+		wantErr    string
+	}{
+		{
+			name: "text",
+			events: []string{
+				messageStart,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":", world"}}`,
+				`{"type":"content_block_stop","index":0}`,
+				`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}`,
+				messageStop,
+			},
+			wantText:   "Hello, world",
+			wantChunks: "Hello, world",
+			wantUsage:  [2]int{12, 5},
+		},
+		{
+			name: "tool use",
+			events: []string{
+				messageStart,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Reading"}}`,
+				`{"type":"content_block_stop","index":0}`,
+				`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"fs__read","input":{}}}`,
+				`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}`,
+				`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":" \"a.txt\"}"}}`,
+				`{"type":"content_block_stop","index":1}`,
+				`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":20}}`,
+				messageStop,
+			},
+			wantText:   "Reading",
+			wantChunks: "Reading",
+			wantCalls:  []string{`toolu_1 fs__read {"path":"a.txt"}`},
+			wantUsage:  [2]int{12, 20},
+		},
+		{
+			name: "tool use without input",
+			events: []string{
+				messageStart,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"clock__now","input":{}}}`,
+				`{"type":"content_block_stop","index":0}`,
+				messageStop,
+			},
+			wantCalls: []string{`toolu_1 clock__now {}`},
+			wantUsage: [2]int{12, 1},
+		},
+		{
+			name: "error event",
+			events: []string{
+				messageStart,
+				`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			},
+			wantErr: "overloaded_error: Overloaded",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := streamServer(t, test.events...)
+			provider := NewProvider("key", server.URL, "claude", "", llm.GenerationParams{})
+
+			var chunks strings.Builder
+			message, err := provider.StreamMessage(context.Background(), "", nil, nil, func(chunk llm.StreamChunk) {
+				chunks.WriteString(chunk.Text)
+			})
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("StreamMessage() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StreamMessage() error = %v", err)
+			}
+
+			if got := message.GetContent(); got != test.wantText {
+				t.Errorf("content = %q, want %q", got, test.wantText)
+			}
+			if got := chunks.String(); got != test.wantChunks {
+				t.Errorf("chunks = %q, want %q", got, test.wantChunks)
+			}
+			var calls []string
+			for _, call := range message.GetToolCalls() {
+				arguments, _ := json.Marshal(call.GetArguments())
+				calls = append(calls, fmt.Sprintf("%s %s %s", call.GetID(), call.GetName(), arguments))
+			}
+			if strings.Join(calls, "\n") != strings.Join(test.wantCalls, "\n") {
+				t.Errorf("tool calls = %q, want %q", calls, test.wantCalls)
+			}
+			if input, output := message.GetUsage(); [2]int{input, output} != test.wantUsage {
+				t.Errorf("usage = %d, %d, want %v", input, output, test.wantUsage)
+			}
+		})
+	}
+}
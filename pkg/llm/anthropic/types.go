@@ -10,11 +10,14 @@ import (
 )
 
 type CreateRequest struct {
-	Model     string         `json:"model"`
-	Messages  []MessageParam `json:"messages"`
-	MaxTokens int            `json:"max_tokens"`
-	System    string         `json:"system,omitempty"`
-	Tools     []Tool         `json:"tools,omitempty"`
+	Model       string         `json:"model"`
+	Messages    []MessageParam `json:"messages"`
+	MaxTokens   int            `json:"max_tokens"`
+	Temperature *float64       `json:"temperature,omitempty"`
+	TopP        *float64       `json:"top_p,omitempty"`
+	System      string         `json:"system,omitempty"`
+	Tools       []Tool         `json:"tools,omitempty"`
+	Stream      bool           `json:"stream,omitempty"`
 }
 
 type MessageParam struct {
@@ -60,6 +63,29 @@ type Usage struct {
 	OutputTokens int `json:"output_tokens"`
 }
 
+// StreamEvent is a single server-sent event of a streamed message
+type StreamEvent struct {
+	Type         string        `json:"type"`
+	Index        int           `json:"index"`
+	Message      *APIMessage   `json:"message,omitempty"`
+	ContentBlock *ContentBlock `json:"content_block,omitempty"`
+	Delta        *StreamDelta  `json:"delta,omitempty"`
+	Usage        *Usage        `json:"usage,omitempty"`
+	Error        *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// StreamDelta holds the incremental part of content_block_delta and
+// message_delta events
+type StreamDelta struct {
+	Type        string  `json:"type"`
+	Text        string  `json:"text,omitempty"`
+	PartialJSON string  `json:"partial_json,omitempty"`
+	StopReason  *string `json:"stop_reason,omitempty"`
+}
+
 // Message implements the llm.Message interface
 type Message struct {
 	Msg APIMessage
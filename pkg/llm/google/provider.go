@@ -19,7 +19,7 @@ type Provider struct {
 	toolCallID int
 }
 
-func NewProvider(ctx context.Context, apiKey, model, systemPrompt string) (*Provider, error) {
+func NewProvider(ctx context.Context, apiKey, model, systemPrompt string, params llm.GenerationParams) (*Provider, error) {
 	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
 	if err != nil {
 		return nil, err
@@ -29,6 +29,15 @@ func NewProvider(ctx context.Context, apiKey, model, systemPrompt string) (*Prov
 	if systemPrompt != "" {
 		m.SystemInstruction = genai.NewUserContent(genai.Text(systemPrompt))
 	}
+	if params.Temperature != nil {
+		m.SetTemperature(float32(*params.Temperature))
+	}
+	if params.TopP != nil {
+		m.SetTopP(float32(*params.TopP))
+	}
+	if params.MaxTokens > 0 {
+		m.SetMaxOutputTokens(int32(params.MaxTokens))
+	}
 	return &Provider{
 		client: client,
 		model:  m,
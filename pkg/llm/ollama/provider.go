@@ -5,6 +5,8 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/charmbracelet/log"
@@ -24,21 +26,46 @@ type Provider struct {
 	client       *api.Client
 	model        string
 	systemPrompt string
+	params       llm.GenerationParams
+
+	// contextLength is the num_ctx the model is loaded with, Ollama's
+	// default when it is 0
+	contextLength int
 }
 
-// NewProvider creates a new Ollama provider
-func NewProvider(model string, systemPrompt string) (*Provider, error) {
-	client, err := api.ClientFromEnvironment()
-	if err != nil {
-		return nil, err
+// NewProvider creates a new Ollama provider. Without a base URL the client
+// is configured from the OLLAMA_HOST environment variable.
+func NewProvider(baseURL, model, systemPrompt string, params llm.GenerationParams) (*Provider, error) {
+	var client *api.Client
+	if baseURL != "" {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base URL: %w", err)
+		}
+		client = api.NewClient(u, http.DefaultClient)
+	} else {
+		var err error
+		client, err = api.ClientFromEnvironment()
+		if err != nil {
+			return nil, err
+		}
 	}
 	return &Provider{
 		client:       client,
 		model:        model,
 		systemPrompt: systemPrompt,
+		params:       params,
 	}, nil
 }
 
+// SetContextLength makes Ollama load the model with a context of the given
+// number of tokens. Ollama's default is much smaller than what most models
+// hold, and the part of a conversation that doesn't fit is dropped by Ollama
+// without notice.
+func (p *Provider) SetContextLength(tokens int) {
+	p.contextLength = tokens
+}
+
 func (p *Provider) CreateMessage(
 	ctx context.Context,
 	prompt string,
@@ -50,6 +77,73 @@ func (p *Provider) CreateMessage(
 		"num_messages", len(messages),
 		"num_tools", len(tools))
 
+	req := p.chatRequest(prompt, messages, tools)
+	req.Stream = boolPtr(false)
+
+	var response api.Message
+	err := p.client.Chat(ctx, req, func(r api.ChatResponse) error {
+		if r.Done {
+			response = r.Message
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &OllamaMessage{Message: response}, nil
+}
+
+func (p *Provider) StreamMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+	onChunk func(llm.StreamChunk),
+) (llm.Message, error) {
+	log.Debug("streaming message",
+		"prompt", prompt,
+		"num_messages", len(messages),
+		"num_tools", len(tools))
+
+	req := p.chatRequest(prompt, messages, tools)
+	req.Stream = boolPtr(true)
+
+	response := api.Message{Role: "assistant"}
+	var content strings.Builder
+
+	err := p.client.Chat(ctx, req, func(r api.ChatResponse) error {
+		if r.Message.Content != "" {
+			content.WriteString(r.Message.Content)
+			onChunk(llm.StreamChunk{Text: r.Message.Content})
+		}
+		// Ollama sends every tool call complete in a single chunk
+		for _, call := range r.Message.ToolCalls {
+			response.ToolCalls = append(response.ToolCalls, call)
+			onChunk(llm.StreamChunk{
+				ToolName:      call.Function.Name,
+				ToolArguments: call.Function.Arguments.String(),
+			})
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	response.Content = content.String()
+	return &OllamaMessage{Message: response}, nil
+}
+
+// chatRequest converts the conversation and tools into a chat request. The
+// caller decides whether the response is streamed.
+func (p *Provider) chatRequest(
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) *api.ChatRequest {
 	// Convert generic messages to Ollama format
 	ollamaMessages := make([]api.Message, 0, len(messages)+1)
 
@@ -170,33 +264,30 @@ func (p *Provider) CreateMessage(
 		}
 	}
 
-	var response api.Message
-	log.Debug("creating message",
-		"prompt", prompt,
-		"num_messages", len(messages),
-		"num_tools", len(tools))
-
 	log.Debug("sending messages to Ollama",
 		"messages", ollamaMessages,
 		"num_tools", len(tools))
 
-	err := p.client.Chat(ctx, &api.ChatRequest{
+	options := make(map[string]interface{})
+	if p.params.Temperature != nil {
+		options["temperature"] = *p.params.Temperature
+	}
+	if p.params.TopP != nil {
+		options["top_p"] = *p.params.TopP
+	}
+	if p.params.MaxTokens > 0 {
+		options["num_predict"] = p.params.MaxTokens
+	}
+	if p.contextLength > 0 {
+		options["num_ctx"] = p.contextLength
+	}
+
+	return &api.ChatRequest{
 		Model:    p.model,
 		Messages: ollamaMessages,
 		Tools:    ollamaTools,
-		Stream:   boolPtr(false),
-	}, func(r api.ChatResponse) error {
-		if r.Done {
-			response = r.Message
-		}
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
+		Options:  options,
 	}
-
-	return &OllamaMessage{Message: response}, nil
 }
 
 func (p *Provider) SupportsTools() bool {
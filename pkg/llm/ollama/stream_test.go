@@ -0,0 +1,116 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcphost/pkg/llm"
+	api "github.com/ollama/ollama/api"
+)
+
+// chatServer answers every chat request with the given lines of JSON, the
+// way Ollama streams its responses
+func chatServer(t *testing.T, lines ...string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		var req api.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Stream == nil || !*req.Stream {
+			t.Errorf("request isn't a streaming request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestStreamMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []string
+		wantText   string
+		wantChunks string
+		wantCalls  []string
+		wantErr    string
+	}{
+		{
+			name: "text",
+			lines: []string{
+				`{"model":"llama3","message":{"role":"assistant","content":"Hello"},"done":false}`,
+				`{"model":"llama3","message":{"role":"assistant","content":", world"},"done":false}`,
+				`{"model":"llama3","message":{"role":"assistant","content":""},"done":true}`,
+			},
+			wantText:   "Hello, world",
+			wantChunks: "Hello, world",
+		},
+		{
+			name: "tool calls",
+			lines: []string{
+				`{"model":"llama3","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"fs__read","arguments":{"path":"a"}}}]},"done":false}`,
+				`{"model":"llama3","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"fs__stat","arguments":{"path":"b"}}}]},"done":false}`,
+				`{"model":"llama3","message":{"role":"assistant","content":""},"done":true}`,
+			},
+			wantCalls: []string{
+				`fs__read {"path":"a"}`,
+				`fs__stat {"path":"b"}`,
+			},
+		},
+		{
+			name: "error",
+			lines: []string{
+				`{"model":"llama3","message":{"role":"assistant","content":"Hel"},"done":false}`,
+				`{"error":"model ran out of memory"}`,
+			},
+			wantErr: "model ran out of memory",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := chatServer(t, test.lines...)
+			provider, err := NewProvider(server.URL, "llama3", "", llm.GenerationParams{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var chunks strings.Builder
+			message, err := provider.StreamMessage(context.Background(), "", nil, nil, func(chunk llm.StreamChunk) {
+				chunks.WriteString(chunk.Text)
+			})
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("StreamMessage() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StreamMessage() error = %v", err)
+			}
+
+			if got := message.GetContent(); got != test.wantText {
+				t.Errorf("content = %q, want %q", got, test.wantText)
+			}
+			if got := chunks.String(); got != test.wantChunks {
+				t.Errorf("chunks = %q, want %q", got, test.wantChunks)
+			}
+			var calls []string
+			for _, call := range message.GetToolCalls() {
+				arguments, _ := json.Marshal(call.GetArguments())
+				calls = append(calls, fmt.Sprintf("%s %s", call.GetName(), arguments))
+			}
+			if strings.Join(calls, "\n") != strings.Join(test.wantCalls, "\n") {
+				t.Errorf("tool calls = %q, want %q", calls, test.wantCalls)
+			}
+		})
+	}
+}
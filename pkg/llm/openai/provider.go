@@ -15,6 +15,7 @@ type Provider struct {
 	client       *Client
 	model        string
 	systemPrompt string
+	params       llm.GenerationParams
 }
 
 func convertSchema(schema llm.Schema) map[string]interface{} {
@@ -31,11 +32,12 @@ func convertSchema(schema llm.Schema) map[string]interface{} {
 	}
 }
 
-func NewProvider(apiKey, baseURL, model, systemPrompt string) *Provider {
+func NewProvider(apiKey, baseURL, model, systemPrompt string, params llm.GenerationParams) *Provider {
 	return &Provider{
 		client:       NewClient(apiKey, baseURL),
 		model:        model,
 		systemPrompt: systemPrompt,
+		params:       params,
 	}
 }
 
@@ -50,6 +52,108 @@ func (p *Provider) CreateMessage(
 		"num_messages", len(messages),
 		"num_tools", len(tools))
 
+	req, err := p.createRequest(prompt, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make the API call
+	resp, err := p.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in response")
+	}
+
+	return &Message{Resp: resp, Choice: &resp.Choices[0]}, nil
+}
+
+func (p *Provider) StreamMessage(
+	ctx context.Context,
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+	onChunk func(llm.StreamChunk),
+) (llm.Message, error) {
+	log.Debug("streaming message",
+		"prompt", prompt,
+		"num_messages", len(messages),
+		"num_tools", len(tools))
+
+	req, err := p.createRequest(prompt, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &APIResponse{}
+	choice := Choice{Message: MessageParam{Role: "assistant"}}
+	var content strings.Builder
+
+	err = p.client.StreamChatCompletion(ctx, req, func(chunk ChunkResponse) error {
+		if resp.ID == "" {
+			resp.ID = chunk.ID
+			resp.Object = chunk.Object
+			resp.Created = chunk.Created
+			resp.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			resp.Usage = *chunk.Usage
+		}
+
+		for _, c := range chunk.Choices {
+			if c.Index != 0 {
+				continue
+			}
+			if c.FinishReason != nil {
+				choice.FinishReason = *c.FinishReason
+			}
+			if c.Delta.Content != "" {
+				content.WriteString(c.Delta.Content)
+				onChunk(llm.StreamChunk{Text: c.Delta.Content})
+			}
+			for _, delta := range c.Delta.ToolCalls {
+				for len(choice.Message.ToolCalls) <= delta.Index {
+					choice.Message.ToolCalls = append(choice.Message.ToolCalls, ToolCall{Type: "function"})
+				}
+				call := &choice.Message.ToolCalls[delta.Index]
+				if delta.ID != "" {
+					call.ID = delta.ID
+				}
+				if delta.Function.Name != "" {
+					call.Function.Name = delta.Function.Name
+				}
+				call.Function.Arguments += delta.Function.Arguments
+				onChunk(llm.StreamChunk{
+					ToolCallID:    call.ID,
+					ToolName:      call.Function.Name,
+					ToolArguments: delta.Function.Arguments,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if content.Len() > 0 {
+		text := content.String()
+		choice.Message.Content = &text
+	}
+	resp.Choices = []Choice{choice}
+
+	return &Message{Resp: resp, Choice: &resp.Choices[0]}, nil
+}
+
+// createRequest converts the conversation and tools into a chat completion
+// request
+func (p *Provider) createRequest(
+	prompt string,
+	messages []llm.Message,
+	tools []llm.Tool,
+) (CreateRequest, error) {
 	openaiMessages := make([]MessageParam, 0, len(messages))
 
 	// Add system prompt if provided
@@ -86,7 +190,7 @@ func (p *Provider) CreateMessage(
 			for i, call := range toolCalls {
 				args, err := json.Marshal(call.GetArguments())
 				if err != nil {
-					return nil, fmt.Errorf(
+					return CreateRequest{}, fmt.Errorf(
 						"error marshaling function arguments: %w",
 						err,
 					)
@@ -176,23 +280,23 @@ func (p *Provider) CreateMessage(
 		}
 	}
 
-	// Make the API call
-	resp, err := p.client.CreateChatCompletion(ctx, CreateRequest{
-		Model:       p.model,
-		Messages:    openaiMessages,
-		Tools:       openaiTools,
-		MaxTokens:   4096,
-		Temperature: 0.7,
-	})
-	if err != nil {
-		return nil, err
+	maxTokens := 4096
+	if p.params.MaxTokens > 0 {
+		maxTokens = p.params.MaxTokens
 	}
-
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in response")
+	temperature := 0.7
+	if p.params.Temperature != nil {
+		temperature = *p.params.Temperature
 	}
 
-	return &Message{Resp: resp, Choice: &resp.Choices[0]}, nil
+	return CreateRequest{
+		Model:       p.model,
+		Messages:    openaiMessages,
+		Tools:       openaiTools,
+		MaxTokens:   maxTokens,
+		Temperature: &temperature,
+		TopP:        p.params.TopP,
+	}, nil
 }
 
 func (p *Provider) SupportsTools() bool {
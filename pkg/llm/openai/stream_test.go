@@ -0,0 +1,138 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcphost/pkg/llm"
+)
+
+// streamServer answers every request with the given chunks as server-sent
+// events
+func streamServer(t *testing.T, chunks ...string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req CreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream {
+			t.Errorf("request isn't a streaming request: %v", err)
+		}
+		if req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+			t.Errorf("request doesn't ask for usage")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestStreamMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		chunks     []string
+		wantText   string
+		wantChunks string
+		wantCalls  []string
+		wantUsage  [2]int
+		wantErr    string
+	}{
+		{
+			name: "text",
+			chunks: []string{
+				`{"id":"c1","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"}}]}`,
+				`{"id":"c1","choices":[{"index":0,"delta":{"content":", world"}}]}`,
+				`{"id":"c1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+				`{"id":"c1","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":5,"total_tokens":17}}`,
+				`[DONE]`,
+			},
+			wantText:   "Hello, world",
+			wantChunks: "Hello, world",
+			wantUsage:  [2]int{12, 5},
+		},
+		{
+			name: "tool calls",
+			chunks: []string{
+				`{"id":"c1","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"fs__read","arguments":""}}]}}]}`,
+				`{"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":"}}]}}]}`,
+				`{"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"fs__stat","arguments":"{\"path\":\"b\"}"}}]}}]}`,
+				`{"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"a\"}"}}]}}]}`,
+				`{"id":"c1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+				`[DONE]`,
+			},
+			wantCalls: []string{
+				`call_1 fs__read {"path":"a"}`,
+				`call_2 fs__stat {"path":"b"}`,
+			},
+		},
+		{
+			name: "other choices ignored",
+			chunks: []string{
+				`{"id":"c1","choices":[{"index":0,"delta":{"content":"one"}},{"index":1,"delta":{"content":"two"}}]}`,
+				`[DONE]`,
+			},
+			wantText:   "one",
+			wantChunks: "one",
+		},
+		{
+			name: "ends without done",
+			chunks: []string{
+				`{"id":"c1","choices":[{"index":0,"delta":{"content":"Hi"}}]}`,
+			},
+			wantText:   "Hi",
+			wantChunks: "Hi",
+		},
+		{
+			name: "invalid chunk",
+			chunks: []string{
+				`{"id":"c1","choices":[{"index":0,"delta":{"content":"Hi"}}]}`,
+				`{"id":`,
+			},
+			wantErr: "error decoding stream chunk",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := streamServer(t, test.chunks...)
+			provider := NewProvider("key", server.URL, "gpt-4o", "", llm.GenerationParams{})
+
+			var chunks strings.Builder
+			message, err := provider.StreamMessage(context.Background(), "", nil, nil, func(chunk llm.StreamChunk) {
+				chunks.WriteString(chunk.Text)
+			})
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("StreamMessage() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StreamMessage() error = %v", err)
+			}
+
+			if got := message.GetContent(); got != test.wantText {
+				t.Errorf("content = %q, want %q", got, test.wantText)
+			}
+			if got := chunks.String(); got != test.wantChunks {
+				t.Errorf("chunks = %q, want %q", got, test.wantChunks)
+			}
+			var calls []string
+			for _, call := range message.GetToolCalls() {
+				arguments, _ := json.Marshal(call.GetArguments())
+				calls = append(calls, fmt.Sprintf("%s %s %s", call.GetID(), call.GetName(), arguments))
+			}
+			if strings.Join(calls, "\n") != strings.Join(test.wantCalls, "\n") {
+				t.Errorf("tool calls = %q, want %q", calls, test.wantCalls)
+			}
+			if input, output := message.GetUsage(); [2]int{input, output} != test.wantUsage {
+				t.Errorf("usage = %d, %d, want %v", input, output, test.wantUsage)
+			}
+		})
+	}
+}
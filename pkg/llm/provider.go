@@ -49,6 +49,20 @@ type Schema struct {
 	Required   []string               `json:"required"`
 }
 
+// GenerationParams tune how a model generates its responses. Unset values
+// leave the defaults of the provider.
+type GenerationParams struct {
+	// Temperature controls the randomness of the response
+	Temperature *float64 `json:"temperature,omitempty"`
+
+	// TopP limits sampling to the most likely tokens whose probabilities add
+	// up to TopP
+	TopP *float64 `json:"topP,omitempty"`
+
+	// MaxTokens is the most tokens a response may have
+	MaxTokens int `json:"maxTokens,omitempty"`
+}
+
 // Provider defines the interface for LLM providers
 type Provider interface {
 	// CreateMessage sends a message to the LLM and returns the response
@@ -63,3 +77,32 @@ type Provider interface {
 	// Name returns the provider's name
 	Name() string
 }
+
+// StreamChunk is a piece of a response delivered while it is being generated
+type StreamChunk struct {
+	// Text is a delta of the assistant's text output
+	Text string
+
+	// ToolCallID and ToolName identify the tool call a chunk belongs to.
+	// Providers that don't assign IDs while streaming leave ToolCallID empty.
+	ToolCallID string
+	ToolName   string
+
+	// ToolArguments is a fragment of the tool call's JSON encoded arguments
+	ToolArguments string
+}
+
+// StreamingProvider is implemented by providers that can stream responses
+type StreamingProvider interface {
+	Provider
+
+	// StreamMessage works like CreateMessage but calls onChunk for every piece
+	// of the response as it arrives. The returned Message holds the full response.
+	StreamMessage(
+		ctx context.Context,
+		prompt string,
+		messages []Message,
+		tools []Tool,
+		onChunk func(StreamChunk),
+	) (Message, error)
+}
@@ -0,0 +1,77 @@
+package llm
+
+import "strings"
+
+// TokenCounter is implemented by providers that know better than the
+// default how many tokens the text of their models takes. The counts are
+// estimates from the length of the text, not the provider's tokenizer.
+type TokenCounter interface {
+	// CountTokens estimates the number of tokens text takes
+	CountTokens(text string) int
+}
+
+// CountTokens estimates the number of tokens text takes for the models of a
+// provider. Providers that aren't a TokenCounter are estimated at four
+// bytes per token, which is close for English text and on the safe side for
+// most other text. Code, non-Latin text and JSON may take more tokens, so
+// limits based on the estimate should leave some room.
+func CountTokens(provider Provider, text string) int {
+	if counter, ok := provider.(TokenCounter); ok {
+		return counter.CountTokens(text)
+	}
+	return EstimateTokens(text, 4)
+}
+
+// EstimateTokens estimates the number of tokens of text from the average
+// number of bytes per token
+func EstimateTokens(text string, bytesPerToken float64) int {
+	if text == "" {
+		return 0
+	}
+	return int(float64(len(text))/bytesPerToken) + 1
+}
+
+// defaultContextLength is the context length of models that aren't in the
+// table. It is kept small so that unknown models aren't overflowed.
+const defaultContextLength = 8192
+
+// contextLengths are the context lengths in tokens of known models, by the
+// start of their names. The longest matching name wins. Ollama models
+// aren't listed: Ollama loads them with the context it is asked for, so they
+// get the default, which is also what they are loaded with.
+var contextLengths = map[string]int{
+	// Anthropic
+	"claude-": 200000,
+
+	// OpenAI
+	"gpt-3.5-turbo": 16385,
+	"gpt-4":         8192,
+	"gpt-4-turbo":   128000,
+	"gpt-4o":        128000,
+	"gpt-4.1":       1047576,
+	"o1":            200000,
+	"o3":            200000,
+	"o4-mini":       200000,
+
+	// Google
+	"gemini-1.5-pro":   2097152,
+	"gemini-1.5-flash": 1048576,
+	"gemini-2.0":       1048576,
+	"gemini-2.5":       1048576,
+}
+
+// ContextLength returns the number of tokens the context of a model holds,
+// for a model given as provider:model or just by its name
+func ContextLength(model string) int {
+	if _, name, ok := strings.Cut(model, ":"); ok {
+		model = name
+	}
+
+	length, longest := defaultContextLength, 0
+	for prefix, tokens := range contextLengths {
+		if strings.HasPrefix(model, prefix) && len(prefix) > longest {
+			length, longest = tokens, len(prefix)
+		}
+	}
+	return length
+}
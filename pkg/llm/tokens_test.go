@@ -0,0 +1,33 @@
+package llm
+
+import "testing"
+
+func TestContextLength(t *testing.T) {
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{model: "anthropic:claude-sonnet-4-20250514", want: 200000},
+		{model: "claude-3-5-haiku-latest", want: 200000},
+		{model: "openai:gpt-4", want: 8192},
+		{model: "openai:gpt-4-0613", want: 8192},
+		{model: "openai:gpt-4-turbo-2024-04-09", want: 128000},
+		{model: "openai:gpt-4o-mini", want: 128000},
+		{model: "openai:gpt-4.1-nano", want: 1047576},
+		{model: "openai:gpt-3.5-turbo", want: 16385},
+		{model: "openai:o3-mini", want: 200000},
+		{model: "google:gemini-1.5-flash-8b", want: 1048576},
+		{model: "google:gemini-2.5-pro", want: 1048576},
+		{model: "ollama:llama3.1:8b", want: defaultContextLength},
+		{model: "openai:my-finetune", want: defaultContextLength},
+		{model: "", want: defaultContextLength},
+	}
+
+	for _, test := range tests {
+		t.Run(test.model, func(t *testing.T) {
+			if got := ContextLength(test.model); got != test.want {
+				t.Errorf("ContextLength(%q) = %d, want %d", test.model, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcphost/pkg/history"
+)
+
+// ErrNotFound is returned when a session does not exist in the store
+var ErrNotFound = errors.New("session not found")
+
+// Session is a conversation that can be saved and resumed later
+type Session struct {
+	ID        string                   `json:"id"`
+	Model     string                   `json:"model,omitempty"`
+	CreatedAt time.Time                `json:"created_at"`
+	UpdatedAt time.Time                `json:"updated_at"`
+	Messages  []history.HistoryMessage `json:"messages"`
+}
+
+// New creates an empty session with a fresh ID
+func New(model string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:        NewID(),
+		Model:     model,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Messages:  make([]history.HistoryMessage, 0),
+	}
+}
+
+// NewID returns a session ID that sorts by creation time
+func NewID() string {
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%s", time.Now().Format("20060102-150405"), hex.EncodeToString(suffix))
+}
+
+// Title returns the first user prompt of the session, shortened for display
+func (s *Session) Title() string {
+	for i := range s.Messages {
+		if s.Messages[i].Role != "user" {
+			continue
+		}
+		if title := strings.Join(strings.Fields(s.Messages[i].GetContent()), " "); title != "" {
+			if runes := []rune(title); len(runes) > 60 {
+				title = string(runes[:57]) + "..."
+			}
+			return title
+		}
+	}
+	return "(empty)"
+}
+
+// Store keeps sessions as JSON files in a directory
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store that keeps its sessions in dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultDir returns the default sessions directory, $HOME/.mcphost/sessions
+func DefaultDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".mcphost", "sessions"), nil
+}
+
+// Dir returns the directory the store writes to
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save writes the session to disk, replacing any earlier version of it
+func (s *Store) Save(sess *Session) error {
+	path, err := s.path(sess.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("error creating sessions directory: %w", err)
+	}
+
+	sess.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding session: %w", err)
+	}
+
+	// Write to a temporary file first so an interrupted save never leaves
+	// a truncated session behind
+	tmp, err := os.CreateTemp(s.dir, sess.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating session file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing session file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing session file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing session file: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads the session with the given ID
+func (s *Store) Load(id string) (*Session, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("error reading session %s: %w", id, err)
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("error parsing session %s: %w", id, err)
+	}
+	if sess.Messages == nil {
+		sess.Messages = make([]history.HistoryMessage, 0)
+	}
+
+	return &sess, nil
+}
+
+// List returns all saved sessions, most recently updated first
+func (s *Store) List() ([]*Session, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading sessions directory: %w", err)
+	}
+
+	var sessions []*Session
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		sess, err := s.Load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, sess)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+
+	return sessions, nil
+}
+
+// Latest returns the most recently updated session
+func (s *Store) Latest() (*Session, error) {
+	sessions, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, ErrNotFound
+	}
+	return sessions[0], nil
+}
+
+func (s *Store) path(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid session id: %q", id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
@@ -0,0 +1,139 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcphost/pkg/history"
+)
+
+func TestStorePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{name: "id", id: "20250101-120000-abcdef"},
+		{name: "empty", id: "", wantErr: true},
+		{name: "parent", id: "..", wantErr: true},
+		{name: "hidden", id: ".config", wantErr: true},
+		{name: "traversal", id: "../../etc/passwd", wantErr: true},
+		{name: "subdirectory", id: "a/b", wantErr: true},
+		{name: "absolute", id: "/tmp/session", wantErr: true},
+	}
+
+	dir := t.TempDir()
+	store := NewStore(dir)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path, err := store.path(test.id)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("path(%q) = %q, want an error", test.id, path)
+				}
+				if _, err := store.Load(test.id); err == nil || errors.Is(err, ErrNotFound) {
+					t.Errorf("Load(%q) error = %v, want an invalid id", test.id, err)
+				}
+				if err := store.Save(&Session{ID: test.id}); err == nil {
+					t.Errorf("Save(%q) error = nil, want an invalid id", test.id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("path(%q) error = %v", test.id, err)
+			}
+			if want := filepath.Join(dir, test.id+".json"); path != want {
+				t.Errorf("path(%q) = %q, want %q", test.id, path, want)
+			}
+		})
+	}
+}
+
+func TestStoreSave(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup prepares the store before the session is saved again
+		setup   func(t *testing.T, store *Store, id string)
+		wantErr bool
+	}{
+		{name: "replace"},
+		{
+			// The session can't be renamed over a directory, so the save
+			// fails after the temporary file was written
+			name: "rename fails",
+			setup: func(t *testing.T, store *Store, id string) {
+				path, _ := store.path(id)
+				if err := os.Remove(path); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Mkdir(path, 0700); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewStore(filepath.Join(t.TempDir(), "sessions"))
+			sess := New("anthropic:claude-3-5-sonnet-latest")
+			sess.Messages = append(sess.Messages, history.HistoryMessage{
+				Role:    "user",
+				Content: []history.ContentBlock{{Type: "text", Text: "hello"}},
+			})
+			if err := store.Save(sess); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			if test.setup != nil {
+				test.setup(t, store, sess.ID)
+			}
+
+			sess.Messages = append(sess.Messages, history.HistoryMessage{
+				Role:    "assistant",
+				Content: []history.ContentBlock{{Type: "text", Text: "hi"}},
+			})
+			err := store.Save(sess)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("Save() error = nil, want an error")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Save() error = %v", err)
+				}
+				loaded, err := store.Load(sess.ID)
+				if err != nil {
+					t.Fatalf("Load() error = %v", err)
+				}
+				if len(loaded.Messages) != 2 || loaded.Title() != "hello" {
+					t.Errorf("loaded %d messages titled %q, want 2 titled hello", len(loaded.Messages), loaded.Title())
+				}
+			}
+
+			// No temporary file is left behind either way
+			entries, err := os.ReadDir(store.Dir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if strings.HasSuffix(entry.Name(), ".tmp") {
+					t.Errorf("temporary file %s left behind", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestStoreLoadNotFound(t *testing.T) {
+	store := NewStore(t.TempDir())
+	if _, err := store.Load("20250101-120000-abcdef"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load() error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Latest(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Latest() error = %v, want ErrNotFound", err)
+	}
+}